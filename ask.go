@@ -0,0 +1,76 @@
+package webot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAskPending is returned by Context.Ask when another Ask is already
+// waiting for the same user in the same chat.
+var ErrAskPending = errors.New("webot: already waiting for an answer from this user")
+
+// SetAskTimeout sets the maximum time Context.Ask waits for an answer,
+// defaults to 5 minutes.
+func (s *Server) SetAskTimeout(timeout time.Duration) *Server {
+	s.askTimeout = timeout
+	return s
+}
+
+// Ask sends the question and blocks until the user who sent the message
+// answers in the same chat, ctx is done or the ask timeout expires. The
+// answer is not passed to the other handlers.
+func (c *Context) Ask(ctx context.Context, question string) (string, error) {
+	s := c.server
+	key := sessionKey(c.Message.CallbackMessageCommonItem)
+	ch := make(chan string, 1)
+	s.askMu.Lock()
+	if _, ok := s.askWaiters[key]; ok {
+		s.askMu.Unlock()
+		return "", ErrAskPending
+	}
+	s.askWaiters[key] = ch
+	s.askMu.Unlock()
+	defer func() {
+		s.askMu.Lock()
+		if s.askWaiters[key] == ch {
+			delete(s.askWaiters, key)
+		}
+		s.askMu.Unlock()
+	}()
+
+	if err := c.ReplyText(question); err != nil {
+		return "", err
+	}
+	if s.askTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.askTimeout)
+		defer cancel()
+	}
+	select {
+	case answer := <-ch:
+		return answer, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// deliverAnswer passes the text to a pending Ask of its sender, it reports
+// whether there was one.
+func (s *Server) deliverAnswer(msg *CallbackMessage) bool {
+	if msg.MsgType != CallbackMessageTypeText {
+		return false
+	}
+	key := sessionKey(msg.CallbackMessageCommonItem)
+	s.askMu.Lock()
+	ch, ok := s.askWaiters[key]
+	if ok {
+		delete(s.askWaiters, key)
+	}
+	s.askMu.Unlock()
+	if !ok {
+		return false
+	}
+	ch <- s.cleanContent(msg.Text.Content)
+	return true
+}
@@ -0,0 +1,137 @@
+package webot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when the async handler queue can't accept more messages.
+	ErrQueueFull = errors.New("webot: handler queue is full")
+	// ErrServerClosed is returned when a message arrives after the server has been shut down.
+	ErrServerClosed = errors.New("webot: server closed")
+)
+
+// AsyncOptions configures asynchronous handler execution, see Server.EnableAsync.
+type AsyncOptions struct {
+	// Workers is the number of goroutines running handlers, defaults to 4.
+	Workers int
+	// QueueSize is the maximum number of messages waiting for a worker, defaults to 100.
+	QueueSize int
+	// HandlerTimeout limits how long a single handler may run, zero means no
+	// limit. A timed out handler has its context canceled and keeps running
+	// until it returns, Shutdown waits for it.
+	HandlerTimeout time.Duration
+}
+
+type asyncDispatcher struct {
+	server  *Server
+	queue   chan CallbackMessage
+	timeout time.Duration
+	wg      sync.WaitGroup
+	// handlers tracks the handler goroutines, which outlive the worker
+	// when they time out.
+	handlers sync.WaitGroup
+	mu       sync.RWMutex
+	closed   bool
+}
+
+func newAsyncDispatcher(s *Server, opts AsyncOptions) *asyncDispatcher {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	d := &asyncDispatcher{
+		server:  s,
+		queue:   make(chan CallbackMessage, opts.QueueSize),
+		timeout: opts.HandlerTimeout,
+	}
+	d.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+func (d *asyncDispatcher) submit(msg CallbackMessage) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return ErrServerClosed
+	}
+	select {
+	case d.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (d *asyncDispatcher) work() {
+	defer d.wg.Done()
+	for msg := range d.queue {
+		d.server.dispatch(msg, nil)
+	}
+}
+
+// run calls fn, giving up waiting for it once the handler timeout expires,
+// in which case the context passed to fn is canceled.
+func (d *asyncDispatcher) run(ctx *Context, fn func(ctx *Context) error) (err error) {
+	if d.timeout <= 0 {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("handler panic: %v", r)
+			}
+		}()
+		return fn(ctx)
+	}
+	c, cancel := context.WithTimeout(ctx.Context, d.timeout)
+	defer cancel()
+	ctx = ctx.withContext(c)
+	done := make(chan error, 1)
+	d.handlers.Add(1)
+	go func() {
+		defer d.handlers.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("handler panic: %v", r)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("handler timed out after %s", d.timeout)
+	}
+}
+
+// shutdown stops accepting messages and waits until the queued ones are
+// handled, including the handlers still running after their timeout.
+func (d *asyncDispatcher) shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		d.handlers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package webot
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imroc/webot/internal/tests"
+)
+
+func shutdownTestServer(t *testing.T, s *Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tests.AssertNoError(t, s.Shutdown(ctx))
+}
+
+func TestAsyncAcknowledgesImmediately(t *testing.T) {
+	s := newTestServer(t).EnableAsync(AsyncOptions{Workers: 1})
+	release := make(chan struct{})
+	var handled atomic.Bool
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		<-release
+		handled.Store(true)
+		return nil
+	})
+	rec := serveTestText(t, s, "m1", "hello")
+	if rec.Code != http.StatusOK || handled.Load() {
+		t.Fatalf("status = %d, handled = %v, want an immediate 200", rec.Code, handled.Load())
+	}
+	close(release)
+	shutdownTestServer(t, s)
+	if !handled.Load() {
+		t.Fatal("the message was not handled")
+	}
+}
+
+func TestAsyncQueueFull(t *testing.T) {
+	s := newTestServer(t).
+		EnableAsync(AsyncOptions{Workers: 1, QueueSize: 1}).
+		EnableDedup(nil, time.Minute)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []string
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		handled = append(handled, ctx.Message.MsgId)
+		mu.Unlock()
+		return nil
+	})
+
+	serveTestText(t, s, "m1", "hello")
+	<-started // m1 is running, the queue is empty
+	serveTestText(t, s, "m2", "hello")
+	if rec := serveTestText(t, s, "m3", "hello"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	close(release)
+	// Wait for m1 and m2 so that the redelivery of m3 fits in the queue.
+	<-started
+	for {
+		mu.Lock()
+		n := len(handled)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if rec := serveTestText(t, s, "m3", "hello"); rec.Code != http.StatusOK {
+		t.Fatalf("redelivery status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	shutdownTestServer(t, s)
+	if want := []string{"m1", "m2", "m3"}; strings.Join(handled, ",") != strings.Join(want, ",") {
+		t.Fatalf("handled %q, want %q", handled, want)
+	}
+}
+
+func TestAsyncHandlerTimeout(t *testing.T) {
+	logs := &testBuffer{}
+	s := newTestServer(t).
+		SetLogger(NewLogger(logs, "", log.LstdFlags)).
+		EnableAsync(AsyncOptions{Workers: 1, HandlerTimeout: 20 * time.Millisecond})
+	ctxErr := make(chan error, 1)
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		<-ctx.Done()
+		ctxErr <- ctx.Err()
+		return nil
+	})
+	serveTestText(t, s, "m1", "hello")
+	if err := <-ctxErr; err != context.DeadlineExceeded {
+		t.Fatalf("ctx.Err() = %v, want %v", err, context.DeadlineExceeded)
+	}
+	shutdownTestServer(t, s)
+	if !strings.Contains(logs.String(), "handler timed out") {
+		t.Fatalf("timeout not logged in %q", logs.String())
+	}
+}
+
+func TestAsyncShutdownDrains(t *testing.T) {
+	s := newTestServer(t).
+		SetLogger(NewLogger(io.Discard, "", log.LstdFlags)).
+		EnableAsync(AsyncOptions{Workers: 1, HandlerTimeout: 10 * time.Millisecond})
+	var handled, finished atomic.Int32
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		handled.Add(1)
+		// Ignore the canceled context and outlive the timeout.
+		time.Sleep(50 * time.Millisecond)
+		finished.Add(1)
+		return nil
+	})
+	for _, id := range []string{"m1", "m2", "m3"} {
+		serveTestText(t, s, id, "hello")
+	}
+	shutdownTestServer(t, s)
+	if handled.Load() != 3 || finished.Load() != 3 {
+		t.Fatalf("handled %d, finished %d, want 3 and 3", handled.Load(), finished.Load())
+	}
+	if rec := serveTestText(t, s, "m4", "hello"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status after shutdown = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
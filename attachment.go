@@ -0,0 +1,38 @@
+package webot
+
+// AttachmentActionHandler handles one action of an attachment callback.
+type AttachmentActionHandler func(ctx *Context, attachment Attachment, action Actions) error
+
+type attachmentActionRoute struct {
+	callbackId string
+	name       string
+	handler    AttachmentActionHandler
+}
+
+// HandleAttachmentAction registers a handler called for every action of
+// the attachment callbacks matching callbackId and the action name, an
+// empty callbackId or name matches any.
+func (s *Server) HandleAttachmentAction(callbackId, name string, fn AttachmentActionHandler, opts ...HandlerOption) *Handle {
+	return s.attachmentActionHandlers.add("", attachmentActionRoute{
+		callbackId: callbackId,
+		name:       name,
+		handler:    fn,
+	}, opts)
+}
+
+func (s *Server) handleAttachmentActions(ctx *Context, attachment Attachment) {
+	routes := s.attachmentActionHandlers.get("")
+	for _, action := range attachment.Actions {
+		action := action
+		for _, route := range routes {
+			if route.callbackId != "" && route.callbackId != attachment.CallbackId {
+				continue
+			}
+			if route.name != "" && route.name != action.Name {
+				continue
+			}
+			handler := route.handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, attachment, action) })
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package webot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type AuditDirection string
+
+const (
+	AuditInbound  AuditDirection = "inbound"
+	AuditOutbound AuditDirection = "outbound"
+)
+
+// AuditRecord is a message received or sent by a bot.
+type AuditRecord struct {
+	Time      time.Time      `json:"time"`
+	Direction AuditDirection `json:"direction"`
+	RobotName string         `json:"robot_name,omitempty"`
+	// MsgId is the id of the received message, or of the message a passive
+	// reply answers.
+	MsgId   string           `json:"msg_id,omitempty"`
+	Message *CallbackMessage `json:"message,omitempty"`
+	// Payload is the outgoing message.
+	Payload any `json:"payload,omitempty"`
+	// WebhookKeyHash is the hex sha256 of the webhook key the payload was
+	// sent to, or of the received message's webhook key.
+	WebhookKeyHash string        `json:"webhook_key_hash,omitempty"`
+	Latency        time.Duration `json:"latency,omitempty"`
+	Result         *Response     `json:"result,omitempty"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// AuditSink receives a record of every message received and sent.
+type AuditSink interface {
+	Audit(record *AuditRecord) error
+}
+
+// SetAuditSink sets the sink receiving every message sent by the client.
+func (client *Client) SetAuditSink(sink AuditSink) {
+	client.audit = sink
+}
+
+// SetAuditSink sets the sink receiving every decoded callback message and
+// passive reply, it is also set on the server's client for the messages it
+// sends.
+func (s *Server) SetAuditSink(sink AuditSink) *Server {
+	s.audit = sink
+	s.client.SetAuditSink(sink)
+	return s
+}
+
+func (client *Client) auditSend(r *Request, webhookUrl string, payload any, start time.Time, result *Response, err error) {
+	if client.audit == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:           start,
+		Direction:      AuditOutbound,
+		RobotName:      r.robotName,
+		MsgId:          r.msgId,
+		Payload:        payload,
+		WebhookKeyHash: webhookKeyHash(webhookUrl),
+		Latency:        time.Since(start),
+		Result:         result,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := client.audit.Audit(record); err != nil {
+		client.log.Errorf("failed to audit sent message: %v", err)
+	}
+}
+
+func (s *Server) auditMessage(msg *CallbackMessage) {
+	if s.audit == nil {
+		return
+	}
+	// The webhook key is only recorded as a hash.
+	redacted := *msg
+	redacted.WebhookUrl = s.redactor.Redact(msg.WebhookUrl)
+	redacted.ResponseUrl = s.redactor.Redact(msg.ResponseUrl)
+	err := s.audit.Audit(&AuditRecord{
+		Time:           time.Now(),
+		Direction:      AuditInbound,
+		RobotName:      s.robotName,
+		MsgId:          msg.MsgId,
+		Message:        &redacted,
+		WebhookKeyHash: webhookKeyHash(msg.WebhookUrl),
+	})
+	if err != nil {
+		s.msgLogger(msg).Errorf("failed to audit message: %v", err)
+	}
+}
+
+// auditReply records a reply written in the callback response, its latency
+// is measured from when the callback was received.
+func (s *Server) auditReply(msg *CallbackMessage, received time.Time, replyMsg *ReplyMessage, err error) {
+	if s.audit == nil {
+		return
+	}
+	record := &AuditRecord{
+		Time:           received,
+		Direction:      AuditOutbound,
+		RobotName:      s.robotName,
+		MsgId:          msg.MsgId,
+		Payload:        replyMsg,
+		WebhookKeyHash: webhookKeyHash(msg.WebhookUrl),
+		Latency:        time.Since(received),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if err := s.audit.Audit(record); err != nil {
+		s.msgLogger(msg).Errorf("failed to audit reply: %v", err)
+	}
+}
+
+func webhookKeyHash(webhookUrl string) string {
+	u, err := url.Parse(strings.TrimSpace(webhookUrl))
+	if err != nil {
+		return ""
+	}
+	key := u.Query().Get("key")
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// FileAuditSink is an AuditSink that appends records as JSON lines to a
+// file, rotating it once it grows over a size limit.
+type FileAuditSink struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewFileAuditSink opens the file at path for appending. The file is
+// rotated once it exceeds maxSize bytes, zero meaning never, and only the
+// latest maxBackups rotated files are kept, zero meaning all.
+func NewFileAuditSink(path string, maxSize int64, maxBackups int) (*FileAuditSink, error) {
+	f := &FileAuditSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *FileAuditSink) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *FileAuditSink) Audit(record *AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(data)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := f.file.Write(data)
+	f.size += int64(n)
+	return err
+}
+
+// rotate renames the current file with a timestamp suffix, removes the
+// oldest backups and opens a new file.
+func (f *FileAuditSink) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup := f.path + "." + time.Now().Format("20060102T150405.000000000")
+	if err := os.Rename(f.path, backup); err != nil {
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return err
+	}
+	if f.maxBackups > 0 {
+		backups, _ := filepath.Glob(f.path + ".*")
+		sort.Strings(backups)
+		for len(backups) > f.maxBackups {
+			os.Remove(backups[0])
+			backups = backups[1:]
+		}
+	}
+	return f.open()
+}
+
+// Close closes the file, records audited afterwards are rejected.
+func (f *FileAuditSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,105 @@
+package webot
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+)
+
+// memoryAuditSink keeps the audited records.
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (m *memoryAuditSink) Audit(record *AuditRecord) error {
+	m.mu.Lock()
+	m.records = append(m.records, *record)
+	m.mu.Unlock()
+	return nil
+}
+
+func TestAuditPassiveReply(t *testing.T) {
+	sink := &memoryAuditSink{}
+	s := newTestServer(t).SetAuditSink(sink)
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		return ctx.RespondText("pong")
+	})
+	msg := strings.Replace(string(newTestTextMessage(t, "m1", "ping")),
+		"https://qyapi.weixin.qq.com/xxxxxxx", "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=secret", 1)
+	rec := serveTestTextMessage(t, s, []byte(msg))
+	if rec.Code != 200 {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if len(sink.records) != 2 {
+		t.Fatalf("%d records, want 2", len(sink.records))
+	}
+	in, out := sink.records[0], sink.records[1]
+	hash := webhookKeyHash("https://x/?key=secret")
+	if in.Direction != AuditInbound || in.WebhookKeyHash != hash || strings.Contains(in.Message.WebhookUrl, "secret") {
+		t.Fatalf("unexpected inbound record %+v", in)
+	}
+	if out.Direction != AuditOutbound || out.MsgId != "m1" || out.WebhookKeyHash != hash || out.Latency <= 0 {
+		t.Fatalf("unexpected outbound record %+v", out)
+	}
+}
+
+func TestFileAuditSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.jsonl")
+	const maxSize, maxBackups = 512, 2
+	sink, err := NewFileAuditSink(path, maxSize, maxBackups)
+	tests.AssertNoError(t, err)
+	for i := 0; i < 50; i++ {
+		tests.AssertNoError(t, sink.Audit(&AuditRecord{
+			Direction: AuditOutbound,
+			Payload:   map[string]any{"seq": i, "text": strings.Repeat("x", 50)},
+		}))
+	}
+	tests.AssertNoError(t, sink.Close())
+	if err := sink.Audit(&AuditRecord{}); !errors.Is(err, os.ErrClosed) {
+		t.Fatalf("Audit after Close = %v, want %v", err, os.ErrClosed)
+	}
+
+	backups, err := filepath.Glob(path + ".*")
+	tests.AssertNoError(t, err)
+	if len(backups) != maxBackups {
+		t.Fatalf("%d backups, want %d", len(backups), maxBackups)
+	}
+	lastSeq := -1
+	for _, name := range append(backups, path) {
+		info, err := os.Stat(name)
+		tests.AssertNoError(t, err)
+		if info.Size() > maxSize {
+			t.Errorf("%s is %d bytes, over %d", name, info.Size(), maxSize)
+		}
+		f, err := os.Open(name)
+		tests.AssertNoError(t, err)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var record struct {
+				Payload struct {
+					Seq int `json:"seq"`
+				} `json:"payload"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				t.Fatalf("%s: invalid line %q: %v", name, scanner.Text(), err)
+			}
+			if record.Payload.Seq <= lastSeq {
+				t.Fatalf("%s: record %d after %d", name, record.Payload.Seq, lastSeq)
+			}
+			lastSeq = record.Payload.Seq
+		}
+		f.Close()
+	}
+	if lastSeq != 49 {
+		t.Fatalf("last record is %d, want 49", lastSeq)
+	}
+}
@@ -11,66 +11,89 @@ const (
 	CallbackMessageTypeTemplateCardEvent CallbackMessageType = "template_card_event"
 	CallbackMessageTypeInteraction       CallbackMessageType = "interaction"
 	CallbackMessageTypeModalSubmit       CallbackMessageType = "modal_submit"
+	CallbackMessageTypeStream            CallbackMessageType = "stream"
 )
 
 type CallbackMessageCommonItem struct {
-	WebhookUrl     string              `xml:"WebhookUrl"`
-	CallbackId     *string             `xml:"CallbackId"`
-	ChatId         string              `xml:"ChatId"`
-	PostId         string              `xml:"PostId"`
-	ChatType       string              `xml:"ChatType"`
-	GetChatInfoUrl string              `xml:"GetChatInfoUrl"`
-	MsgId          string              `xml:"MsgId"`
-	MsgType        CallbackMessageType `xml:"MsgType"`
-	From           From                `xml:"From"`
-	AppVersion     string              `xml:"AppVersion"`
+	WebhookUrl     string              `xml:"WebhookUrl" json:"webhook_url"`
+	CallbackId     *string             `xml:"CallbackId" json:"callbackid"`
+	ChatId         string              `xml:"ChatId" json:"chatid"`
+	PostId         string              `xml:"PostId" json:"postid"`
+	ChatType       string              `xml:"ChatType" json:"chattype"`
+	GetChatInfoUrl string              `xml:"GetChatInfoUrl" json:"get_chat_info_url"`
+	MsgId          string              `xml:"MsgId" json:"msgid"`
+	MsgType        CallbackMessageType `xml:"MsgType" json:"msgtype"`
+	From           From                `xml:"From" json:"from"`
+	AppVersion     string              `xml:"AppVersion" json:"appversion"`
+	AibotId        string              `xml:"AibotId" json:"aibotid"`
+	ResponseUrl    string              `xml:"ResponseUrl" json:"response_url"`
 }
 
 type CallbackMessage struct {
-	Text        *Text        `xml:"Text,omitempty"`
-	Image       *Image       `xml:"Image,omitempty"`
-	Event       *Event       `xml:"Event,omitempty"`
-	Attachment  *Attachment  `xml:"Attachment,omitempty"`
-	Interaction *Interaction `xml:"Interaction,omitempty"`
-	ModalSubmit *ModalSubmit `xml:"ModalSubmit,omitempty"`
+	Text        *Text        `xml:"Text,omitempty" json:"text,omitempty"`
+	Image       *Image       `xml:"Image,omitempty" json:"image,omitempty"`
+	Event       *Event       `xml:"Event,omitempty" json:"event,omitempty"`
+	Attachment  *Attachment  `xml:"Attachment,omitempty" json:"attachment,omitempty"`
+	Interaction *Interaction `xml:"Interaction,omitempty" json:"interaction,omitempty"`
+	ModalSubmit *ModalSubmit `xml:"ModalSubmit,omitempty" json:"modal_submit,omitempty"`
+	Stream      *Stream      `xml:"Stream,omitempty" json:"stream,omitempty"`
 	CallbackMessageCommonItem
 }
 
 type From struct {
-	UserId string `xml:"UserId"`
-	Name   string `xml:"Name"`
-	Alias  string `xml:"Alias"`
+	UserId string `xml:"UserId" json:"userid"`
+	Name   string `xml:"Name" json:"name"`
+	Alias  string `xml:"Alias" json:"alias"`
 }
 
 type Text struct {
-	Content string `xml:"Content"`
+	Content string `xml:"Content" json:"content"`
 }
 
 type Image struct {
-	ImageUrl string `xml:"ImageUrl"`
+	ImageUrl string `xml:"ImageUrl" json:"url"`
 }
 
+type EventType string
+
+const (
+	// EventTypeEnterChat is sent when a user opens the single chat with the bot.
+	EventTypeEnterChat EventType = "enter_chat"
+	// EventTypeAddToChat is sent when the bot is added to a group chat.
+	EventTypeAddToChat EventType = "add_to_chat"
+	// EventTypeDeleteFromChat is sent when the bot is removed from a group chat.
+	EventTypeDeleteFromChat EventType = "delete_from_chat"
+)
+
 type Event struct {
-	EventType string `xml:"EventType"`
+	EventType EventType `xml:"EventType" json:"eventtype"`
 }
 
 type Attachment struct {
-	CallbackId string  `xml:"CallbackId"`
-	Actions    Actions `xml:"Actions"`
+	CallbackId string    `xml:"CallbackId" json:"callbackid"`
+	Actions    []Actions `xml:"Actions" json:"actions"`
+}
+
+// Action returns the first action of the attachment.
+func (a Attachment) Action() Actions {
+	if len(a.Actions) == 0 {
+		return Actions{}
+	}
+	return a.Actions[0]
 }
 
 type Actions struct {
-	Name  string `xml:"Name"`
-	Value string `xml:"Value"`
-	Type  string `xml:"Type"`
+	Name  string `xml:"Name" json:"name"`
+	Value string `xml:"Value" json:"value"`
+	Type  string `xml:"Type" json:"type"`
 }
 
 type Interaction struct {
-	ReportData *string `xml:"ReportData"`
-	InputText  *string `xml:"InputText"`
-	InputJson  *string `xml:"InputJson"`
+	ReportData *string `xml:"ReportData" json:"report_data"`
+	InputText  *string `xml:"InputText" json:"input_text"`
+	InputJson  *string `xml:"InputJson" json:"input_json"`
 }
 
 type ModalSubmit struct {
-	InputJson string `xml:"InputJson"`
+	InputJson string `xml:"InputJson" json:"input_json"`
 }
@@ -1,6 +1,7 @@
 package webot
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/imroc/req/v3"
@@ -10,33 +11,60 @@ type Client struct {
 	client     *req.Client
 	webhookURL string
 	uploadURL  string
+	pending    pendingCounter
+	log        Logger
+	redactor   *Redactor
+	audit      AuditSink
 }
 
 func NewClient() *Client {
-	return &Client{
-		client: req.C().SetResultStateCheckFunc(func(resp *req.Response) req.ResultState {
-			if errCode := resp.GetHeader("Error-Code"); errCode == "0" {
-				return req.SuccessState
-			}
-			return req.ErrorState
-		}).OnAfterResponse(func(client *req.Client, resp *req.Response) error {
-			if errCode := resp.GetHeader("Error-Code"); errCode == "0" {
-				return nil
-			}
-			resp.Err = fmt.Errorf("Error-Code: %s, Error-Msg: %s", resp.GetHeader("Error-Code"), resp.GetHeader("Error-Msg"))
-			return nil
-		}),
+	client := &Client{
+		log:      createDefaultLogger(),
+		redactor: NewRedactor(),
 	}
+	client.client = req.C().SetResultStateCheckFunc(func(resp *req.Response) req.ResultState {
+		if errCode := resp.GetHeader("Error-Code"); errCode == "0" {
+			return req.SuccessState
+		}
+		return req.ErrorState
+	}).OnAfterResponse(func(client *req.Client, resp *req.Response) error {
+		if errCode := resp.GetHeader("Error-Code"); errCode == "0" {
+			return nil
+		}
+		resp.Err = fmt.Errorf("Error-Code: %s, Error-Msg: %s", resp.GetHeader("Error-Code"), resp.GetHeader("Error-Msg"))
+		return nil
+	})
+	client.client.SetLogger(newRedactLogger(client.log, client.redactor))
+	return client
 }
 
 func (client *Client) Client() *req.Client {
 	return client.client
 }
 
+// SetLogger sets the logger which receives the debug logs and dumps, with
+// the secrets masked by the client's Redactor.
+func (client *Client) SetLogger(logger Logger) {
+	client.log = logger
+	client.client.SetLogger(newRedactLogger(logger, client.redactor))
+}
+
+// SetRedactor sets the Redactor used for dumps, errors and logs.
+func (client *Client) SetRedactor(redactor *Redactor) {
+	client.redactor = redactor
+	client.client.SetLogger(newRedactLogger(client.log, redactor))
+}
+
+// SetDumpRequest enables dumping requests and responses to the logger.
 func (client *Client) SetDumpRequest(dump bool) {
 	if dump {
-		client.client.EnableDumpAll().EnableDebugLog().EnableTraceAll()
+		client.client.EnableDumpAllTo(dumpWriter{client}).EnableDebugLog().EnableTraceAll()
 	} else {
 		client.client.DisableDebugLog().DisableDumpAll().DisableTraceAll()
 	}
 }
+
+// Flush waits until the messages being sent are done.
+func (client *Client) Flush(ctx context.Context) error {
+	return client.pending.wait(ctx)
+}
@@ -3,14 +3,26 @@ package webot
 type ComponentType string
 
 const (
-	ComponentTypeTextInput ComponentType = "text_input"
+	ComponentTypeTextInput   ComponentType = "text_input"
+	ComponentTypeTextArea    ComponentType = "text_area"
+	ComponentTypeSelect      ComponentType = "select"
+	ComponentTypeMultiSelect ComponentType = "multi_select"
+	ComponentTypeCheckbox    ComponentType = "checkbox"
+	ComponentTypeDatePicker  ComponentType = "date_picker"
+	ComponentTypeTimePicker  ComponentType = "time_picker"
 )
 
 type Component interface {
 	_is_component()
 }
 
-func (c *TextInputComponent) _is_component() {}
+func (c *TextInputComponent) _is_component()   {}
+func (c *TextAreaComponent) _is_component()    {}
+func (c *SelectComponent) _is_component()      {}
+func (c *MultiSelectComponent) _is_component() {}
+func (c *CheckboxComponent) _is_component()    {}
+func (c *DatePickerComponent) _is_component()  {}
+func (c *TimePickerComponent) _is_component()  {}
 
 type TextInputComponent struct {
 	Type       ComponentType  `json:"type"`
@@ -25,3 +37,65 @@ type TextInputLabel struct {
 	Location *string `json:"location"`
 	Width    *string `json:"width"`
 }
+
+type TextAreaComponent struct {
+	Type       ComponentType  `json:"type"`
+	Hint       string         `json:"hint"`
+	Key        string         `json:"key"`
+	AllowEmpty *bool          `json:"allow_empty"`
+	MaxLength  int            `json:"max_length,omitempty"`
+	Label      TextInputLabel `json:"label"`
+}
+
+type SelectOption struct {
+	Id   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type SelectComponent struct {
+	Type       ComponentType  `json:"type"`
+	Hint       string         `json:"hint"`
+	Key        string         `json:"key"`
+	AllowEmpty *bool          `json:"allow_empty"`
+	Label      TextInputLabel `json:"label"`
+	Options    []SelectOption `json:"option_list"`
+	SelectedId string         `json:"selected_id,omitempty"`
+}
+
+type MultiSelectComponent struct {
+	Type        ComponentType  `json:"type"`
+	Hint        string         `json:"hint"`
+	Key         string         `json:"key"`
+	AllowEmpty  *bool          `json:"allow_empty"`
+	Label       TextInputLabel `json:"label"`
+	Options     []SelectOption `json:"option_list"`
+	SelectedIds []string       `json:"selected_id_list,omitempty"`
+	MaxSelected int            `json:"max_selected,omitempty"`
+}
+
+type CheckboxComponent struct {
+	Type       ComponentType  `json:"type"`
+	Key        string         `json:"key"`
+	AllowEmpty *bool          `json:"allow_empty"`
+	Label      TextInputLabel `json:"label"`
+	Options    []SelectOption `json:"option_list"`
+	CheckedIds []string       `json:"checked_id_list,omitempty"`
+}
+
+type DatePickerComponent struct {
+	Type        ComponentType  `json:"type"`
+	Hint        string         `json:"hint"`
+	Key         string         `json:"key"`
+	AllowEmpty  *bool          `json:"allow_empty"`
+	Label       TextInputLabel `json:"label"`
+	InitialDate string         `json:"initial_date,omitempty"` // 2006-01-02
+}
+
+type TimePickerComponent struct {
+	Type        ComponentType  `json:"type"`
+	Hint        string         `json:"hint"`
+	Key         string         `json:"key"`
+	AllowEmpty  *bool          `json:"allow_empty"`
+	Label       TextInputLabel `json:"label"`
+	InitialTime string         `json:"initial_time,omitempty"` // 15:04
+}
@@ -0,0 +1,97 @@
+package webot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Context is passed to message handlers, it carries the received message and
+// offers helpers to reply to it.
+type Context struct {
+	context.Context
+	Message CallbackMessage
+	Client  *Client
+	Logger  Logger
+	server  *Server
+	state   *contextState
+	reply   *passiveReply
+}
+
+// contextState is shared by all the copies of a Context.
+type contextState struct {
+	mu      sync.RWMutex
+	values  map[string]any
+	stopped atomic.Bool
+}
+
+func (s *Server) newContext(ctx context.Context, msg CallbackMessage) *Context {
+	return &Context{
+		Context: ctx,
+		Message: msg,
+		Client:  s.client,
+		Logger:  s.msgLogger(&msg),
+		server:  s,
+		state:   &contextState{values: make(map[string]any)},
+	}
+}
+
+// withContext returns a shallow copy of c using ctx, the state is shared.
+func (c *Context) withContext(ctx context.Context) *Context {
+	cc := *c
+	cc.Context = ctx
+	return &cc
+}
+
+// Set stores a value which is visible to the handlers running after this one.
+func (c *Context) Set(key string, value any) {
+	c.state.mu.Lock()
+	c.state.values[key] = value
+	c.state.mu.Unlock()
+}
+
+func (c *Context) Get(key string) (value any, ok bool) {
+	c.state.mu.RLock()
+	value, ok = c.state.values[key]
+	c.state.mu.RUnlock()
+	return
+}
+
+// StopPropagation prevents the handlers after the current one from running.
+func (c *Context) StopPropagation() {
+	c.state.stopped.Store(true)
+}
+
+func (c *Context) IsPropagationStopped() bool {
+	return c.state.stopped.Load()
+}
+
+// Reply creates a Request targeting the webhook, chat and post of the
+// received message, or its response url for the JSON protocol.
+func (c *Context) Reply() *Request {
+	url := c.Message.WebhookUrl
+	if url == "" {
+		url = c.Message.ResponseUrl
+	}
+	r := c.Client.NewRequest(url).Reply(c.Message.CallbackMessageCommonItem)
+	r.Request.SetContext(c)
+	r.robotName = c.server.robotName
+	r.msgId = c.Message.MsgId
+	return r
+}
+
+func (c *Context) ReplyText(content string, mentionedList ...string) error {
+	return c.Reply().SendText(&TextMessage{Content: content, MentionedList: mentionedList})
+}
+
+func (c *Context) ReplyMarkdown(content string) error {
+	return c.Reply().SendMarkdown(&MarkdownMessage{Content: content})
+}
+
+func (c *Context) ReplyFile(filename string, content []byte) error {
+	return c.Reply().SendFileContent(filename, content)
+}
+
+func (c *Context) ReplyCard(card *TemplateCardMessage) error {
+	return c.Reply().SendTemplateCard(card)
+}
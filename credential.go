@@ -0,0 +1,141 @@
+package webot
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/imroc/webot/msgcrypt"
+)
+
+// Credential is a token and EncodingAESKey pair of a bot.
+type Credential struct {
+	// Name identifies the credential in CredentialMatches, defaults to
+	// "current" for the current credential and "previous-N" for the others.
+	Name           string
+	Token          string
+	EncodingAESKey string
+}
+
+type serverCredential struct {
+	name      string
+	xmlCrypt  *msgcrypt.Crypt
+	jsonCrypt *msgcrypt.Crypt
+	matches   atomic.Uint64
+}
+
+func newServerCredential(cred Credential, receiverId string) (*serverCredential, error) {
+	xmlCrypt, err := msgcrypt.New(cred.Token, cred.EncodingAESKey, receiverId, msgcrypt.XML)
+	if err != nil {
+		return nil, fmt.Errorf("credential %s: %w", cred.Name, err)
+	}
+	jsonCrypt, err := xmlCrypt.WithProtocol(msgcrypt.JSON)
+	if err != nil {
+		return nil, err
+	}
+	return &serverCredential{name: cred.Name, xmlCrypt: xmlCrypt, jsonCrypt: jsonCrypt}, nil
+}
+
+func (c *serverCredential) crypt(protocol Protocol) *msgcrypt.Crypt {
+	if protocol == ProtocolJSON {
+		return c.jsonCrypt
+	}
+	return c.xmlCrypt
+}
+
+// SetCredentials replaces the credentials of the server. During a rotation
+// window the previous credentials are tried, in order, after the current
+// one fails to verify or decrypt a callback.
+func (s *Server) SetCredentials(current Credential, previous ...Credential) error {
+	if current.Name == "" {
+		current.Name = "current"
+	}
+	// Build under the write lock so a concurrent SetReceiverId isn't lost.
+	s.credMu.Lock()
+	defer s.credMu.Unlock()
+	creds := make([]*serverCredential, 0, 1+len(previous))
+	for i, cred := range append([]Credential{current}, previous...) {
+		if cred.Name == "" {
+			cred.Name = fmt.Sprintf("previous-%d", i)
+		}
+		c, err := newServerCredential(cred, s.receiverId)
+		if err != nil {
+			return err
+		}
+		creds = append(creds, c)
+	}
+	s.credentials = creds
+	return nil
+}
+
+// SetReceiverId sets the receiver id checked on decryption and used on
+// encryption, empty disables the check.
+func (s *Server) SetReceiverId(receiverId string) *Server {
+	s.credMu.Lock()
+	defer s.credMu.Unlock()
+	s.receiverId = receiverId
+	creds := make([]*serverCredential, len(s.credentials))
+	for i, c := range s.credentials {
+		creds[i] = &serverCredential{
+			name:      c.name,
+			xmlCrypt:  c.xmlCrypt.WithReceiverID(receiverId),
+			jsonCrypt: c.jsonCrypt.WithReceiverID(receiverId),
+		}
+		creds[i].matches.Store(c.matches.Load())
+	}
+	s.credentials = creds
+	return s
+}
+
+// CredentialMatches returns how many callbacks each credential verified,
+// keyed by credential name.
+func (s *Server) CredentialMatches() map[string]uint64 {
+	s.credMu.RLock()
+	defer s.credMu.RUnlock()
+	matches := make(map[string]uint64, len(s.credentials))
+	for _, c := range s.credentials {
+		matches[c.name] = c.matches.Load()
+	}
+	return matches
+}
+
+func (s *Server) getCredentials() []*serverCredential {
+	s.credMu.RLock()
+	defer s.credMu.RUnlock()
+	return s.credentials
+}
+
+// decrypt tries the credentials in order and returns the decrypted message
+// with the crypt which matched, to encrypt the reply with.
+func (s *Server) decrypt(protocol Protocol, msgSignature, timestamp, nonce string, body []byte) ([]byte, *msgcrypt.Crypt, error) {
+	var firstErr error
+	for i, c := range s.getCredentials() {
+		crypt := c.crypt(protocol)
+		msg, err := crypt.DecryptMsg(msgSignature, timestamp, nonce, body)
+		if err == nil {
+			c.matches.Add(1)
+			if i > 0 {
+				s.log.Warnf("callback matched credential %s instead of the current one", c.name)
+			}
+			return msg, crypt, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, nil, firstErr
+}
+
+func (s *Server) verifyURL(msgSignature, timestamp, nonce, echoStr string) ([]byte, error) {
+	var firstErr error
+	for _, c := range s.getCredentials() {
+		msg, err := c.xmlCrypt.VerifyURL(msgSignature, timestamp, nonce, echoStr)
+		if err == nil {
+			c.matches.Add(1)
+			return msg, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
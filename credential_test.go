@@ -0,0 +1,36 @@
+package webot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+func TestCredentialRotation(t *testing.T) {
+	s := newTestServer(t)
+	tests.AssertNoError(t, s.SetCredentials(
+		Credential{Token: "newToken", EncodingAESKey: "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"},
+		Credential{Token: testToken, EncodingAESKey: testEncodingAESKey},
+	))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, tests.GetTestFileContent(t, "msg-text.xml")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	matches := s.CredentialMatches()
+	if matches["current"] != 0 || matches["previous-1"] != 1 {
+		t.Fatalf("unexpected matches %v", matches)
+	}
+}
+
+func TestReceiverIdChecked(t *testing.T) {
+	s := newTestServer(t).SetReceiverId("other")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, tests.GetTestFileContent(t, "msg-text.xml")))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
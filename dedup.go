@@ -0,0 +1,138 @@
+package webot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DedupStore records seen keys, it can be shared by several servers to
+// deduplicate callbacks across instances.
+type DedupStore interface {
+	// Add records key for ttl and reports whether it was not recorded yet.
+	Add(key string, ttl time.Duration) (bool, error)
+	// Remove forgets key, so that a message which could not be handled is
+	// accepted again when redelivered.
+	Remove(key string) error
+}
+
+// MemoryDedupStore is a DedupStore that keeps keys in process memory.
+type MemoryDedupStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{entries: make(map[string]time.Time)}
+}
+
+func (m *MemoryDedupStore) Add(key string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastSweep) > ttl {
+		for k, expireAt := range m.entries {
+			if now.After(expireAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	if expireAt, ok := m.entries[key]; ok && now.Before(expireAt) {
+		return false, nil
+	}
+	m.entries[key] = now.Add(ttl)
+	return true, nil
+}
+
+func (m *MemoryDedupStore) Remove(key string) error {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// FileDedupStore is a DedupStore that keeps one file per key in a directory,
+// which can live on a filesystem shared by several instances.
+type FileDedupStore struct {
+	dir       string
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewFileDedupStore(dir string) (*FileDedupStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileDedupStore{dir: dir}, nil
+}
+
+func (f *FileDedupStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:]))
+}
+
+func (f *FileDedupStore) Add(key string, ttl time.Duration) (bool, error) {
+	f.sweep(ttl)
+	name := f.path(key)
+	for i := 0; i < 2; i++ {
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return true, file.Close()
+		}
+		if !os.IsExist(err) {
+			return false, err
+		}
+		info, err := os.Stat(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if time.Since(info.ModTime()) < ttl {
+			return false, nil
+		}
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("dedup key %s keeps being created and removed concurrently", key)
+}
+
+func (f *FileDedupStore) Remove(key string) error {
+	if err := os.Remove(f.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sweep removes expired entries, at most once per ttl.
+func (f *FileDedupStore) sweep(ttl time.Duration) {
+	f.mu.Lock()
+	if time.Since(f.lastSweep) < ttl {
+		f.mu.Unlock()
+		return
+	}
+	f.lastSweep = time.Now()
+	f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		if time.Since(info.ModTime()) >= ttl {
+			os.Remove(filepath.Join(f.dir, entry.Name()))
+		}
+	}
+}
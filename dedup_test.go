@@ -0,0 +1,70 @@
+package webot
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imroc/webot/internal/tests"
+)
+
+func TestDedupStores(t *testing.T) {
+	const ttl = 50 * time.Millisecond
+	fileStore, err := NewFileDedupStore(t.TempDir())
+	tests.AssertNoError(t, err)
+	stores := map[string]DedupStore{
+		"memory": NewMemoryDedupStore(),
+		"file":   fileStore,
+	}
+	steps := []struct {
+		name  string
+		key   string
+		sleep time.Duration
+		// remove forgets the key instead of adding it.
+		remove bool
+		added  bool
+	}{
+		{name: "first add", key: "a", added: true},
+		{name: "duplicate", key: "a", added: false},
+		{name: "other key", key: "b", added: true},
+		{name: "removed key", key: "b", remove: true},
+		{name: "add after remove", key: "b", added: true},
+		{name: "remove unknown key", key: "c", remove: true},
+		{name: "expired key", key: "a", sleep: 2 * ttl, added: true},
+		{name: "duplicate after expiry", key: "a", added: false},
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for _, step := range steps {
+				time.Sleep(step.sleep)
+				if step.remove {
+					tests.AssertNoError(t, store.Remove(step.key))
+					continue
+				}
+				added, err := store.Add(step.key, ttl)
+				tests.AssertNoError(t, err)
+				if added != step.added {
+					t.Fatalf("%s: Add(%q) = %v, want %v", step.name, step.key, added, step.added)
+				}
+			}
+		})
+	}
+}
+
+func TestDedupDropsRedelivery(t *testing.T) {
+	s := newTestServer(t).EnableDedup(nil, time.Minute)
+	var handled atomic.Int32
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		handled.Add(1)
+		return nil
+	})
+	for _, id := range []string{"m1", "m1", "m2", "m1"} {
+		if rec := serveTestText(t, s, id, "hello"); rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+	if n := handled.Load(); n != 2 {
+		t.Fatalf("handled %d messages, want 2", n)
+	}
+}
@@ -0,0 +1,205 @@
+package webot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DialogEnd is returned by a DialogStepHandler to finish the dialog.
+const DialogEnd = ""
+
+// DialogStepHandler handles the user's answer to a step and returns the name
+// of the next step, or DialogEnd. Returning an error keeps the dialog on the
+// current step.
+type DialogStepHandler func(ctx *Context, session *Session, input string) (next string, err error)
+
+// Dialog is a multi-turn conversation defined as a set of named steps.
+type Dialog struct {
+	name     string
+	first    string
+	steps    map[string]*dialogStep
+	onCancel func(ctx *Context, session *Session) error
+}
+
+type dialogStep struct {
+	prompt  string
+	handler DialogStepHandler
+}
+
+func NewDialog(name string) *Dialog {
+	return &Dialog{name: name, steps: make(map[string]*dialogStep)}
+}
+
+// Step adds a step to the dialog, the first added step starts the dialog.
+// The prompt is sent to the user when the dialog enters the step.
+func (d *Dialog) Step(name, prompt string, handler DialogStepHandler) *Dialog {
+	if d.first == "" {
+		d.first = name
+	}
+	d.steps[name] = &dialogStep{prompt: prompt, handler: handler}
+	return d
+}
+
+// OnCancel sets the function called when the user cancels the dialog.
+func (d *Dialog) OnCancel(fn func(ctx *Context, session *Session) error) *Dialog {
+	d.onCancel = fn
+	return d
+}
+
+// RegisterDialog makes the dialog available to Context.StartDialog.
+func (s *Server) RegisterDialog(d *Dialog) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dialogs == nil {
+		s.dialogs = make(map[string]*Dialog)
+	}
+	s.dialogs[d.name] = d
+	return s
+}
+
+// UnregisterDialog removes the named dialog, sessions of the dialog end at
+// the next message.
+func (s *Server) UnregisterDialog(name string) *Server {
+	s.mu.Lock()
+	delete(s.dialogs, name)
+	s.mu.Unlock()
+	return s
+}
+
+func (s *Server) getDialog(name string) (d *Dialog, ok bool) {
+	s.mu.RLock()
+	d, ok = s.dialogs[name]
+	s.mu.RUnlock()
+	return
+}
+
+func (s *Server) hasDialogs() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.dialogs) > 0
+}
+
+// SetSessionStore sets where dialog sessions are kept and how long an idle
+// session lives, defaults to an in-memory store and 10 minutes.
+func (s *Server) SetSessionStore(store SessionStore, ttl time.Duration) *Server {
+	if store == nil {
+		store = NewMemorySessionStore()
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	s.sessionStore = store
+	s.sessionTTL = ttl
+	return s
+}
+
+// SetCancelKeywords sets the messages that cancel a running dialog,
+// defaults to "cancel" and "取消".
+func (s *Server) SetCancelKeywords(keywords ...string) *Server {
+	s.cancelKeywords = keywords
+	return s
+}
+
+func sessionKey(msg CallbackMessageCommonItem) string {
+	return msg.ChatId + "/" + msg.From.UserId
+}
+
+func (s *Server) saveSession(key string, session *Session) error {
+	session.ExpiresAt = time.Now().Add(s.sessionTTL)
+	return s.sessionStore.Save(key, session)
+}
+
+// StartDialog starts the named dialog with the user who sent the message.
+func (c *Context) StartDialog(name string) error {
+	s := c.server
+	d, ok := s.getDialog(name)
+	if !ok {
+		return fmt.Errorf("dialog %q not registered", name)
+	}
+	if d.first == "" {
+		return fmt.Errorf("dialog %q has no step", name)
+	}
+	session := &Session{Dialog: name, Step: d.first, Data: make(map[string]string)}
+	if err := s.saveSession(sessionKey(c.Message.CallbackMessageCommonItem), session); err != nil {
+		return err
+	}
+	if prompt := d.steps[d.first].prompt; prompt != "" {
+		return c.ReplyText(prompt)
+	}
+	return nil
+}
+
+// handleDialog feeds the text to the user's running dialog, it reports
+// whether the message was consumed by a dialog. It does nothing once a
+// handler stopped the propagation.
+func (s *Server) handleDialog(ctx *Context, text Text) bool {
+	if ctx.IsPropagationStopped() || !s.hasDialogs() {
+		return false
+	}
+	key := sessionKey(ctx.Message.CallbackMessageCommonItem)
+	session, err := s.sessionStore.Load(key)
+	if err != nil {
+		ctx.Logger.Errorf("failed to load session %s: %v", key, err)
+		return false
+	}
+	if session == nil {
+		return false
+	}
+	d, ok := s.getDialog(session.Dialog)
+	if !ok {
+		s.sessionStore.Delete(key)
+		return false
+	}
+	input := s.cleanContent(text.Content)
+	for _, keyword := range s.cancelKeywords {
+		if strings.EqualFold(input, keyword) {
+			if err := s.sessionStore.Delete(key); err != nil {
+				ctx.Logger.Errorf("failed to delete session %s: %v", key, err)
+			}
+			if d.onCancel != nil {
+				s.runHandler(ctx, func(ctx *Context) error { return d.onCancel(ctx, session) })
+			}
+			return true
+		}
+	}
+	step, ok := d.steps[session.Step]
+	if !ok {
+		ctx.Logger.Errorf("dialog %s has no step %s", d.name, session.Step)
+		s.sessionStore.Delete(key)
+		return true
+	}
+	// next is only read when runHandler returns nil, i.e. the handler
+	// finished, a timed out handler may still be running and writing it.
+	var next string
+	err = s.runHandler(ctx, func(ctx *Context) (err error) {
+		next, err = step.handler(ctx, session, input)
+		return
+	})
+	if err != nil {
+		return true
+	}
+	if next == DialogEnd {
+		if err := s.sessionStore.Delete(key); err != nil {
+			ctx.Logger.Errorf("failed to delete session %s: %v", key, err)
+		}
+		return true
+	}
+	nextStep, ok := d.steps[next]
+	if !ok {
+		ctx.Logger.Errorf("dialog %s has no step %s", d.name, next)
+		s.sessionStore.Delete(key)
+		return true
+	}
+	session.Step = next
+	if err := s.saveSession(key, session); err != nil {
+		ctx.Logger.Errorf("failed to save session %s: %v", key, err)
+		return true
+	}
+	if nextStep.prompt != "" {
+		if err := ctx.ReplyText(nextStep.prompt); err != nil {
+			ctx.Logger.Errorf("failed to send prompt of step %s: %v", next, err)
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+package webot
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSessionKey = "wrkSFfCgAALFgnrSsWU38puiv4yvExuw/zhangsan"
+
+// newTestDialogServer returns a server starting the "deploy" dialog on the
+// "deploy" message, whose "env" step fails on any input but "prod".
+func newTestDialogServer(t *testing.T) (*Server, *atomic.Bool) {
+	s := newTestServer(t)
+	var canceled atomic.Bool
+	s.RegisterDialog(NewDialog("deploy").
+		Step("env", "which env?", func(ctx *Context, session *Session, input string) (string, error) {
+			if input != "prod" {
+				return "", errors.New("unknown env")
+			}
+			session.Set("env", input)
+			return "confirm", nil
+		}).
+		Step("confirm", "confirm?", func(ctx *Context, session *Session, input string) (string, error) {
+			return DialogEnd, nil
+		}).
+		OnCancel(func(ctx *Context, session *Session) error {
+			canceled.Store(true)
+			return nil
+		}))
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		if text.Content == "deploy" {
+			return ctx.StartDialog("deploy")
+		}
+		return nil
+	})
+	return s, &canceled
+}
+
+func loadTestSession(t *testing.T, s *Server) *Session {
+	session, err := s.sessionStore.Load(testSessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestDialogSteps(t *testing.T) {
+	s, _ := newTestDialogServer(t)
+	webhook := newTestWebhook(t)
+	serveTestTextVia(t, s, webhook, "m1", "deploy")
+	if session := loadTestSession(t, s); session == nil || session.Step != "env" {
+		t.Fatalf("session = %+v, want step env", session)
+	}
+	serveTestTextVia(t, s, webhook, "m2", "prod")
+	session := loadTestSession(t, s)
+	if session == nil || session.Step != "confirm" || session.Get("env") != "prod" {
+		t.Fatalf("session = %+v, want step confirm with env prod", session)
+	}
+	messages := webhook.Messages()
+	if len(messages) != 2 || !strings.Contains(messages[0], "which env?") || !strings.Contains(messages[1], "confirm?") {
+		t.Fatalf("webhook messages = %q, want the prompts of both steps", messages)
+	}
+	serveTestTextVia(t, s, webhook, "m3", "yes")
+	if session := loadTestSession(t, s); session != nil {
+		t.Fatalf("session = %+v, want it deleted at the end of the dialog", session)
+	}
+}
+
+func TestDialogStepError(t *testing.T) {
+	s, _ := newTestDialogServer(t)
+	webhook := newTestWebhook(t)
+	serveTestTextVia(t, s, webhook, "m1", "deploy")
+	serveTestTextVia(t, s, webhook, "m2", "staging")
+	if session := loadTestSession(t, s); session == nil || session.Step != "env" {
+		t.Fatalf("session = %+v, want it kept on step env", session)
+	}
+	if messages := webhook.Messages(); len(messages) != 1 {
+		t.Fatalf("webhook messages = %q, want only the first prompt", messages)
+	}
+}
+
+func TestDialogCancel(t *testing.T) {
+	s, canceled := newTestDialogServer(t)
+	webhook := newTestWebhook(t)
+	serveTestTextVia(t, s, webhook, "m1", "deploy")
+	serveTestTextVia(t, s, webhook, "m2", "Cancel")
+	if session := loadTestSession(t, s); session != nil {
+		t.Fatalf("session = %+v, want it deleted on cancel", session)
+	}
+	if !canceled.Load() {
+		t.Fatal("OnCancel was not called")
+	}
+}
+
+func TestDialogSessionExpiry(t *testing.T) {
+	s, _ := newTestDialogServer(t)
+	s.SetSessionStore(nil, time.Millisecond)
+	webhook := newTestWebhook(t)
+	serveTestTextVia(t, s, webhook, "m1", "deploy")
+	time.Sleep(5 * time.Millisecond)
+	if session := loadTestSession(t, s); session != nil {
+		t.Fatalf("session = %+v, want it expired", session)
+	}
+}
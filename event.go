@@ -0,0 +1,29 @@
+package webot
+
+// HandleEvent registers a handler for the events of the given type.
+func (s *Server) HandleEvent(eventType EventType, fn EventMessageHandler, opts ...HandlerOption) *Handle {
+	return s.eventMessageHandlers.add(string(eventType), fn, opts)
+}
+
+// OnEnterChat registers a handler called when a user enters the single chat with the bot.
+func (s *Server) OnEnterChat(fn EventMessageHandler, opts ...HandlerOption) *Handle {
+	return s.HandleEvent(EventTypeEnterChat, fn, opts...)
+}
+
+// OnAddToChat registers a handler called when the bot is added to a group chat.
+func (s *Server) OnAddToChat(fn EventMessageHandler, opts ...HandlerOption) *Handle {
+	return s.HandleEvent(EventTypeAddToChat, fn, opts...)
+}
+
+// OnDeleteFromChat registers a handler called when the bot is removed from a group chat.
+func (s *Server) OnDeleteFromChat(fn EventMessageHandler, opts ...HandlerOption) *Handle {
+	return s.HandleEvent(EventTypeDeleteFromChat, fn, opts...)
+}
+
+// SetWelcomeMessage responds with the markdown content to every user entering
+// the single chat with the bot.
+func (s *Server) SetWelcomeMessage(content string) *Handle {
+	return s.OnEnterChat(func(ctx *Context, event Event) error {
+		return ctx.RespondMarkdown(content)
+	})
+}
@@ -0,0 +1,37 @@
+// Package ginbot mounts webot servers and routers on gin.
+package ginbot
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imroc/webot"
+)
+
+// Handler returns a gin handler serving the callbacks of server.
+func Handler(server *webot.Server) gin.HandlerFunc {
+	return gin.WrapH(server)
+}
+
+// Register registers the GET (URL verification) and POST (message) callback
+// routes of server at path.
+func Register(routes gin.IRoutes, path string, server *webot.Server) {
+	h := Handler(server)
+	routes.GET(path, h)
+	routes.POST(path, h)
+}
+
+// RegisterRouter registers the callback routes of all bots of router at
+// "/:bot" of routes, typically a route group such as "/callback".
+func RegisterRouter(routes gin.IRoutes, router *webot.Router) {
+	h := func(c *gin.Context) {
+		bot := router.Bot(c.Param("bot"))
+		if bot == nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		bot.ServeHTTP(c.Writer, c.Request)
+	}
+	routes.GET("/:bot", h)
+	routes.POST("/:bot", h)
+}
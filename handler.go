@@ -0,0 +1,91 @@
+package webot
+
+import (
+	"sort"
+	"sync"
+)
+
+// Handle identifies a registered handler and allows to unregister it.
+type Handle struct {
+	once   sync.Once
+	remove func()
+}
+
+// Unregister removes the handler, it's safe to call more than once.
+func (h *Handle) Unregister() {
+	h.once.Do(h.remove)
+}
+
+// HandlerOption configures a handler registration.
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	priority int
+}
+
+// WithPriority sets the priority of the handler, handlers with a higher
+// priority run first, handlers with the same priority run in registration
+// order. The default priority is 0.
+func WithPriority(priority int) HandlerOption {
+	return func(o *handlerOptions) {
+		o.priority = priority
+	}
+}
+
+type handlerEntry[T any] struct {
+	key      string
+	priority int
+	fn       T
+}
+
+// handlerList is a goroutine-safe list of handlers ordered by priority,
+// each handler can be registered under a key.
+type handlerList[T any] struct {
+	mu      sync.RWMutex
+	entries []*handlerEntry[T]
+}
+
+func (l *handlerList[T]) add(key string, fn T, opts []HandlerOption) *Handle {
+	var o handlerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := &handlerEntry[T]{key: key, priority: o.priority, fn: fn}
+	entries := append(l.entries[:len(l.entries):len(l.entries)], entry)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority > entries[j].priority
+	})
+	l.entries = entries
+	return &Handle{remove: func() { l.remove(entry) }}
+}
+
+func (l *handlerList[T]) remove(entry *handlerEntry[T]) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]*handlerEntry[T], 0, len(l.entries))
+	for _, e := range l.entries {
+		if e != entry {
+			entries = append(entries, e)
+		}
+	}
+	l.entries = entries
+}
+
+// get returns the handlers registered under any of keys, in running order.
+func (l *handlerList[T]) get(keys ...string) []T {
+	l.mu.RLock()
+	entries := l.entries
+	l.mu.RUnlock()
+	var handlers []T
+	for _, e := range entries {
+		for _, key := range keys {
+			if e.key == key {
+				handlers = append(handlers, e.fn)
+				break
+			}
+		}
+	}
+	return handlers
+}
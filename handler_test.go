@@ -0,0 +1,127 @@
+package webot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/imroc/webot/msgcrypt"
+)
+
+// recordTextHandler returns a text handler appending name to the order.
+func recordTextHandler(mu *sync.Mutex, order *[]string, name string) TextMessageHandler {
+	return func(ctx *Context, text Text) error {
+		mu.Lock()
+		*order = append(*order, name)
+		mu.Unlock()
+		return nil
+	}
+}
+
+func TestHandlerPriority(t *testing.T) {
+	s := newTestServer(t)
+	var mu sync.Mutex
+	var order []string
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "a"))
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "b"), WithPriority(10))
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "c"))
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "d"), WithPriority(-1))
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "e"), WithPriority(10))
+	serveTestText(t, s, "m1", "hello")
+	if want := []string{"b", "e", "a", "c", "d"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+}
+
+func TestHandleUnregister(t *testing.T) {
+	s := newTestServer(t)
+	var mu sync.Mutex
+	var order []string
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "a"))
+	h := s.HandleTextMessage(recordTextHandler(&mu, &order, "b"))
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "c"))
+	h.Unregister()
+	h.Unregister()
+	serveTestText(t, s, "m1", "hello")
+	if want := []string{"a", "c"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+}
+
+func TestStopPropagation(t *testing.T) {
+	s := newTestServer(t)
+	var mu sync.Mutex
+	var order []string
+	s.HandleTextMessage(recordTextHandler(&mu, &order, "a"))
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		order = append(order, "stop")
+		ctx.StopPropagation()
+		return nil
+	}, WithPriority(1))
+	serveTestText(t, s, "m1", "hello")
+	if want := []string{"stop"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+}
+
+func TestStopPropagationSkipsDialog(t *testing.T) {
+	s := newTestServer(t)
+	var stepRan atomic.Bool
+	s.RegisterDialog(NewDialog("deploy").
+		Step("env", "", func(ctx *Context, session *Session, input string) (string, error) {
+			stepRan.Store(true)
+			return DialogEnd, nil
+		}))
+	key := "wrkSFfCgAALFgnrSsWU38puiv4yvExuw/zhangsan"
+	if err := s.saveSession(key, &Session{Dialog: "deploy", Step: "env"}); err != nil {
+		t.Fatal(err)
+	}
+	s.HandleMessage(func(ctx *Context) error {
+		ctx.StopPropagation()
+		return nil
+	})
+	serveTestText(t, s, "m1", "prod")
+	if stepRan.Load() {
+		t.Fatal("the dialog step ran after StopPropagation")
+	}
+	session, err := s.sessionStore.Load(key)
+	if err != nil || session == nil || session.Step != "env" {
+		t.Fatalf("session = %+v, %v, want it kept on step env", session, err)
+	}
+}
+
+func TestRegisterWhileServing(t *testing.T) {
+	s := newTestServer(t)
+	var handled atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			h := s.HandleTextMessage(func(ctx *Context, text Text) error {
+				handled.Add(1)
+				return nil
+			}, WithPriority(i%3))
+			if i%2 == 0 {
+				h.Unregister()
+			}
+		}
+	}()
+	reqs := make([]*http.Request, 50)
+	for i := range reqs {
+		reqs[i] = newTestCallback(t, msgcrypt.XML, newTestTextMessage(t, "m", "hello"))
+	}
+	go func() {
+		defer wg.Done()
+		for _, req := range reqs {
+			s.ServeHTTP(httptest.NewRecorder(), req)
+		}
+	}()
+	wg.Wait()
+	if n := len(s.textMessageHandlers.get("")); n != 25 {
+		t.Fatalf("%d handlers registered, want 25", n)
+	}
+}
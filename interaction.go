@@ -0,0 +1,83 @@
+package webot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// InteractionHandler handles interaction callbacks.
+type InteractionHandler func(ctx *Context, interaction Interaction) error
+
+// DecodeInteraction unmarshals the JSON ReportData of the interaction into T.
+func DecodeInteraction[T any](interaction Interaction) (T, error) {
+	var v T
+	if interaction.ReportData == nil {
+		return v, errors.New("no report data in interaction")
+	}
+	if err := json.Unmarshal([]byte(*interaction.ReportData), &v); err != nil {
+		return v, fmt.Errorf("failed to decode report data: %w", err)
+	}
+	return v, nil
+}
+
+// DecodeInteractionInput unmarshals the InputJson of the interaction into T,
+// the same way as DecodeModalSubmit.
+func DecodeInteractionInput[T any](interaction Interaction) (T, error) {
+	if interaction.InputJson == nil {
+		var v T
+		return v, errors.New("no input json in interaction")
+	}
+	return decodeInput[T](*interaction.InputJson)
+}
+
+// SetInteractionDiscriminator sets the ReportData field whose value selects
+// the interaction handlers, defaults to "action".
+func (s *Server) SetInteractionDiscriminator(field string) *Server {
+	s.interactionDiscriminator = field
+	return s
+}
+
+// HandleInteraction registers a handler for the interactions whose
+// discriminator field in ReportData equals kind, an empty kind matches all
+// interactions.
+func (s *Server) HandleInteraction(kind string, fn InteractionHandler, opts ...HandlerOption) *Handle {
+	return s.interactionHandlers.add(kind, fn, opts)
+}
+
+// HandleInteractionOf registers a handler for the interactions of kind whose
+// ReportData is decoded into T.
+func HandleInteractionOf[T any](s *Server, kind string, fn func(ctx *Context, data T, interaction Interaction) error, opts ...HandlerOption) *Handle {
+	return s.HandleInteraction(kind, func(ctx *Context, interaction Interaction) error {
+		data, err := DecodeInteraction[T](interaction)
+		if err != nil {
+			return err
+		}
+		return fn(ctx, data, interaction)
+	}, opts...)
+}
+
+func (s *Server) interactionKind(interaction Interaction) string {
+	if interaction.ReportData == nil {
+		return ""
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(*interaction.ReportData), &data); err != nil {
+		return ""
+	}
+	switch kind := data[s.interactionDiscriminator].(type) {
+	case nil:
+		return ""
+	case string:
+		return kind
+	default:
+		return fmt.Sprint(kind)
+	}
+}
+
+func (s *Server) interactionHandlersOf(interaction Interaction) []InteractionHandler {
+	if kind := s.interactionKind(interaction); kind != "" {
+		return s.interactionHandlers.get("", kind)
+	}
+	return s.interactionHandlers.get("")
+}
@@ -0,0 +1,129 @@
+package webot
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// pendingCounter counts in-flight operations so that they can be waited
+// for, like a sync.WaitGroup whose wait can be canceled.
+type pendingCounter struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{} // closed when n drops to zero
+}
+
+func (p *pendingCounter) add() {
+	p.mu.Lock()
+	if p.n == 0 {
+		p.idle = make(chan struct{})
+	}
+	p.n++
+	p.mu.Unlock()
+}
+
+func (p *pendingCounter) done() {
+	p.mu.Lock()
+	p.n--
+	if p.n == 0 {
+		close(p.idle)
+	}
+	p.mu.Unlock()
+}
+
+func (p *pendingCounter) wait(ctx context.Context) error {
+	p.mu.Lock()
+	if p.n == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	idle := p.idle
+	p.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetCallbackPath sets the path of the callback served by ListenAndServe, defaults to "/".
+func (s *Server) SetCallbackPath(path string) *Server {
+	s.callbackPath = path
+	return s
+}
+
+// SetHealthPath sets the path of the health endpoint served by
+// ListenAndServe, defaults to "/healthz".
+func (s *Server) SetHealthPath(path string) *Server {
+	s.healthPath = path
+	return s
+}
+
+// SetTimeouts sets the read and write timeouts of the HTTP server started by ListenAndServe.
+func (s *Server) SetTimeouts(read, write time.Duration) *Server {
+	s.readTimeout = read
+	s.writeTimeout = write
+	return s
+}
+
+// HealthHandler responds 200 while the server accepts callbacks, 503 after Shutdown.
+func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	if s.closing.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+func (s *Server) newHTTPServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.healthPath, s.HealthHandler)
+	mux.HandleFunc(s.callbackPath, s.CallbackHandler)
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  s.readTimeout,
+		WriteTimeout: s.writeTimeout,
+	}
+	s.httpMu.Lock()
+	s.httpServer = srv
+	s.httpMu.Unlock()
+	return srv
+}
+
+// ListenAndServe serves the callback and the health endpoint on addr until Shutdown is called.
+func (s *Server) ListenAndServe(addr string) error {
+	return s.newHTTPServer(addr).ListenAndServe()
+}
+
+// ListenAndServeTLS is like ListenAndServe but serves HTTPS.
+func (s *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	return s.newHTTPServer(addr).ListenAndServeTLS(certFile, keyFile)
+}
+
+// Shutdown stops accepting callbacks, waits for the in-flight handlers,
+// including the queued ones in async mode, and for the pending outgoing
+// messages to be sent.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.closing.Store(true)
+	s.httpMu.Lock()
+	srv := s.httpServer
+	s.httpMu.Unlock()
+	if srv != nil {
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.inflight.wait(ctx); err != nil {
+		return err
+	}
+	if s.async != nil {
+		if err := s.async.shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	return s.client.Flush(ctx)
+}
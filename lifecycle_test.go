@@ -0,0 +1,55 @@
+package webot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+func TestShutdownWaitsForServeHTTP(t *testing.T) {
+	s := newTestServer(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished atomic.Bool
+	s.HandleTextMessage(func(ctx *Context, text Text) error {
+		close(started)
+		<-release
+		finished.Store(true)
+		return nil
+	})
+	msg := tests.GetTestFileContent(t, "msg-text.xml")
+	go s.ServeHTTP(httptest.NewRecorder(), newTestCallback(t, msgcrypt.XML, msg))
+	<-started
+	time.AfterFunc(50*time.Millisecond, func() { close(release) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	tests.AssertNoError(t, s.Shutdown(ctx))
+	if !finished.Load() {
+		t.Fatal("Shutdown returned before the handler finished")
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, msg))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status after shutdown = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestPendingCounterWait(t *testing.T) {
+	var p pendingCounter
+	tests.AssertNoError(t, p.wait(context.Background()))
+	p.add()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("wait = %v, want %v", err, context.DeadlineExceeded)
+	}
+	p.done()
+	tests.AssertNoError(t, p.wait(context.Background()))
+}
@@ -1,9 +1,13 @@
 package webot
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 )
 
 type Logger interface {
@@ -13,9 +17,49 @@ type Logger interface {
 	Infof(format string, v ...interface{})
 }
 
-// NewLogger create a Logger wraps the *log.Logger
+// FieldLogger is a Logger which can carry structured attributes, the server
+// uses it to attach the msg id, chat id and user id to its log lines.
+type FieldLogger interface {
+	Logger
+	// With returns a Logger which adds the key/value pairs to every line.
+	With(args ...any) Logger
+}
+
+// Level is the severity of a log line.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+}
+
+// NewLogger create a Logger wraps the *log.Logger, which writes lines at
+// LevelInfo and above.
 func NewLogger(output io.Writer, prefix string, flag int) Logger {
-	return &logger{l: log.New(output, prefix, flag)}
+	return NewLevelLogger(output, prefix, flag, LevelInfo)
+}
+
+// NewLevelLogger create a Logger wraps the *log.Logger, which only writes
+// lines at level and above.
+func NewLevelLogger(output io.Writer, prefix string, flag int, level Level) Logger {
+	return &logger{l: log.New(output, prefix, flag), level: level}
 }
 
 func createDefaultLogger() Logger {
@@ -23,13 +67,109 @@ func createDefaultLogger() Logger {
 }
 
 type logger struct {
-	l *log.Logger
+	l      *log.Logger
+	level  Level
+	fields string
 }
 
-func (l *logger) Errorf(format string, v ...interface{}) {}
+func (l *logger) output(level Level, format string, v ...interface{}) {
+	if level < l.level {
+		return
+	}
+	l.l.Printf("[%s] %s%s", level, fmt.Sprintf(format, v...), l.fields)
+}
 
-func (l *logger) Warnf(format string, v ...interface{}) {}
+func (l *logger) Errorf(format string, v ...interface{}) {
+	l.output(LevelError, format, v...)
+}
 
-func (l *logger) Debugf(format string, v ...interface{}) {}
+func (l *logger) Warnf(format string, v ...interface{}) {
+	l.output(LevelWarn, format, v...)
+}
 
-func (l *logger) Infof(format string, v ...interface{}) {}
+func (l *logger) Debugf(format string, v ...interface{}) {
+	l.output(LevelDebug, format, v...)
+}
+
+func (l *logger) Infof(format string, v ...interface{}) {
+	l.output(LevelInfo, format, v...)
+}
+
+func (l *logger) With(args ...any) Logger {
+	var b strings.Builder
+	b.WriteString(l.fields)
+	for _, attr := range argsToAttrs(args) {
+		fmt.Fprintf(&b, " %s=%v", attr.Key, attr.Value)
+	}
+	return &logger{l: l.l, level: l.level, fields: b.String()}
+}
+
+// argsToAttrs turns the arguments of With into attributes the same way
+// slog.Logger.With does.
+func argsToAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		switch arg := args[0].(type) {
+		case slog.Attr:
+			attrs = append(attrs, arg)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				attrs = append(attrs, slog.Any("!BADKEY", arg))
+				args = nil
+			} else {
+				attrs = append(attrs, slog.Any(arg, args[1]))
+				args = args[2:]
+			}
+		default:
+			attrs = append(attrs, slog.Any("!BADKEY", arg))
+			args = args[1:]
+		}
+	}
+	return attrs
+}
+
+// NewSlogLogger create a Logger which writes to the *slog.Logger.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (l *slogLogger) log(level slog.Level, format string, v ...interface{}) {
+	ctx := context.Background()
+	if !l.l.Enabled(ctx, level) {
+		return
+	}
+	l.l.Log(ctx, level, fmt.Sprintf(format, v...))
+}
+
+func (l *slogLogger) Errorf(format string, v ...interface{}) {
+	l.log(slog.LevelError, format, v...)
+}
+
+func (l *slogLogger) Warnf(format string, v ...interface{}) {
+	l.log(slog.LevelWarn, format, v...)
+}
+
+func (l *slogLogger) Debugf(format string, v ...interface{}) {
+	l.log(slog.LevelDebug, format, v...)
+}
+
+func (l *slogLogger) Infof(format string, v ...interface{}) {
+	l.log(slog.LevelInfo, format, v...)
+}
+
+func (l *slogLogger) With(args ...any) Logger {
+	return &slogLogger{l: l.l.With(args...)}
+}
+
+// loggerWith adds the key/value pairs to l if it is a FieldLogger.
+func loggerWith(l Logger, args ...any) Logger {
+	if fl, ok := l.(FieldLogger); ok {
+		return fl.With(args...)
+	}
+	return l
+}
@@ -0,0 +1,65 @@
+package webot
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLevelLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLevelLogger(&buf, "", 0, LevelWarn)
+	l.Debugf("debug %d", 1)
+	l.Infof("info %d", 2)
+	l.Warnf("warn %d", 3)
+	l.Errorf("error %d", 4)
+	want := "[WARN] warn 3\n[ERROR] error 4\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestLoggerWith(t *testing.T) {
+	var buf bytes.Buffer
+	l := loggerWith(NewLevelLogger(&buf, "", 0, LevelDebug), "msg_id", "m1")
+	l = loggerWith(l, "chat_id", "c1")
+	l.Debugf("first")
+	l.Errorf("second")
+	want := "[DEBUG] first msg_id=m1 chat_id=c1\n[ERROR] second msg_id=m1 chat_id=c1\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	l := loggerWith(NewSlogLogger(slog.New(handler)), "msg_id", "m1")
+	l.Debugf("hidden")
+	l.Infof("info %d", 1)
+	l.Warnf("warn")
+	l.Errorf("error")
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{
+		`level=INFO msg="info 1" msg_id=m1`,
+		`level=WARN msg=warn msg_id=m1`,
+		`level=ERROR msg=error msg_id=m1`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("output = %q, want %q", lines, want)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("line %d = %q, want %q", i, lines[i], want[i])
+		}
+	}
+}
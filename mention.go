@@ -0,0 +1,109 @@
+package webot
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseMentions returns the names mentioned with "@" in content and the
+// content with the mentions removed. Mentions are separated by any unicode
+// space, including the full-width space, names which contain spaces can be
+// passed as known names.
+func ParseMentions(content string, names ...string) (mentions []string, stripped string) {
+	return parseMentions(content, names, func(string) bool { return true })
+}
+
+// Mentions returns the names mentioned with "@" in the text.
+func (t Text) Mentions() []string {
+	mentions, _ := ParseMentions(t.Content)
+	return mentions
+}
+
+// StripMentions returns the text content without mentions.
+func (t Text) StripMentions() string {
+	_, stripped := ParseMentions(t.Content)
+	return stripped
+}
+
+// IsMentioned reports whether the robot is mentioned in the text.
+func (s *Server) IsMentioned(text Text) bool {
+	if s.robotName == "" {
+		return false
+	}
+	mentions, _ := ParseMentions(text.Content, s.robotName)
+	for _, name := range mentions {
+		if name == s.robotName {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMentioned reports whether the robot is mentioned in the received text message.
+func (c *Context) IsMentioned() bool {
+	return c.Message.Text != nil && c.server.IsMentioned(*c.Message.Text)
+}
+
+// cleanContent removes the mentions of the robot from content.
+func (s *Server) cleanContent(content string) string {
+	if s.robotName == "" {
+		return strings.TrimFunc(content, unicode.IsSpace)
+	}
+	_, stripped := parseMentions(content, []string{s.robotName}, func(name string) bool {
+		return name == s.robotName
+	})
+	return stripped
+}
+
+func isMentionSign(r rune) bool {
+	return r == '@' || r == '＠'
+}
+
+func parseMentions(content string, names []string, strip func(name string) bool) (mentions []string, stripped string) {
+	var b strings.Builder
+	prev := ' '
+	for i := 0; i < len(content); {
+		r, size := utf8.DecodeRuneInString(content[i:])
+		if isMentionSign(r) && unicode.IsSpace(prev) {
+			if name, end := mentionAt(content, i+size, names); name != "" {
+				mentions = append(mentions, name)
+				if strip(name) {
+					if r, size := utf8.DecodeRuneInString(content[end:]); unicode.IsSpace(r) {
+						end += size
+					}
+					i, prev = end, ' '
+					continue
+				}
+				b.WriteString(content[i:end])
+				r, _ = utf8.DecodeLastRuneInString(content[:end])
+				i, prev = end, r
+				continue
+			}
+		}
+		b.WriteString(content[i : i+size])
+		i, prev = i+size, r
+	}
+	return mentions, strings.TrimFunc(b.String(), unicode.IsSpace)
+}
+
+// mentionAt returns the name starting at start and the index after it.
+func mentionAt(content string, start int, names []string) (string, int) {
+	rest := content[start:]
+	for _, name := range names {
+		if name == "" || !strings.HasPrefix(rest, name) {
+			continue
+		}
+		if r, _ := utf8.DecodeRuneInString(rest[len(name):]); len(rest) == len(name) || unicode.IsSpace(r) {
+			return name, start + len(name)
+		}
+	}
+	end := strings.IndexFunc(rest, unicode.IsSpace)
+	if end < 0 {
+		end = len(rest)
+	}
+	if end == 0 {
+		return "", start
+	}
+	return rest[:end], start + end
+}
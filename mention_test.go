@@ -0,0 +1,68 @@
+package webot
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+)
+
+func TestParseMentions(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		names    []string
+		mentions []string
+		stripped string
+	}{
+		{"single", "@RobotA hello robot", nil, []string{"RobotA"}, "hello robot"},
+		{"full-width", "＠RobotA　hello　world", nil, []string{"RobotA"}, "hello　world"},
+		{"multiple", "@RobotA @bob hi @carol", nil, []string{"RobotA", "bob", "carol"}, "hi"},
+		{"no space before sign", "hi@bot", nil, nil, "hi@bot"},
+		{"bare sign", "@", nil, nil, "@"},
+		{"sign followed by space", "@ hello", nil, nil, "@ hello"},
+		{"known name with space", "@Robot A deploy", []string{"Robot A"}, []string{"Robot A"}, "deploy"},
+		{"unknown name with space", "@Robot A deploy", nil, []string{"Robot"}, "A deploy"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mentions, stripped := ParseMentions(c.content, c.names...)
+			if !reflect.DeepEqual(mentions, c.mentions) {
+				t.Errorf("mentions = %q, want %q", mentions, c.mentions)
+			}
+			if stripped != c.stripped {
+				t.Errorf("stripped = %q, want %q", stripped, c.stripped)
+			}
+		})
+	}
+}
+
+func TestIsMentioned(t *testing.T) {
+	var msg CallbackMessage
+	tests.AssertNoError(t, xml.Unmarshal(tests.GetTestFileContent(t, "msg-text.xml"), &msg))
+	s := newTestServer(t)
+	if !s.IsMentioned(*msg.Text) {
+		t.Fatalf("RobotA is not mentioned in %q", msg.Text.Content)
+	}
+	if got := s.cleanContent(msg.Text.Content); got != "hello robot" {
+		t.Fatalf("cleanContent = %q", got)
+	}
+
+	s.robotName = "Robot A"
+	cases := map[string]bool{
+		"@Robot A deploy":   true,
+		"＠Robot A　deploy":   true,
+		"@bob @Robot A hi":  true,
+		"@Robot AB deploy":  false,
+		"hi@Robot A deploy": false,
+	}
+	for content, want := range cases {
+		if got := s.IsMentioned(Text{Content: content}); got != want {
+			t.Errorf("IsMentioned(%q) = %v, want %v", content, got, want)
+		}
+	}
+	if got := s.cleanContent("@bob @Robot A hi"); got != "@bob hi" {
+		t.Errorf("cleanContent = %q", got)
+	}
+}
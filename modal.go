@@ -0,0 +1,150 @@
+package webot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Modal is a form sent to users, its submission is received as a
+// ModalSubmit callback.
+type Modal struct {
+	Title      string      `json:"title"`
+	SubmitText string      `json:"submit_text,omitempty"`
+	Components []Component `json:"component_list"`
+}
+
+func NewModal(title string) *Modal {
+	return &Modal{Title: title}
+}
+
+func modalLabel(text string) TextInputLabel {
+	return TextInputLabel{Text: &text}
+}
+
+func (m *Modal) SetSubmitText(text string) *Modal {
+	m.SubmitText = text
+	return m
+}
+
+// Add adds fully configured components to the modal.
+func (m *Modal) Add(components ...Component) *Modal {
+	m.Components = append(m.Components, components...)
+	return m
+}
+
+func (m *Modal) TextInput(key, label, hint string) *Modal {
+	return m.Add(&TextInputComponent{Type: ComponentTypeTextInput, Key: key, Label: modalLabel(label), Hint: hint})
+}
+
+func (m *Modal) TextArea(key, label, hint string) *Modal {
+	return m.Add(&TextAreaComponent{Type: ComponentTypeTextArea, Key: key, Label: modalLabel(label), Hint: hint})
+}
+
+func (m *Modal) Select(key, label string, options ...SelectOption) *Modal {
+	return m.Add(&SelectComponent{Type: ComponentTypeSelect, Key: key, Label: modalLabel(label), Options: options})
+}
+
+func (m *Modal) MultiSelect(key, label string, options ...SelectOption) *Modal {
+	return m.Add(&MultiSelectComponent{Type: ComponentTypeMultiSelect, Key: key, Label: modalLabel(label), Options: options})
+}
+
+func (m *Modal) Checkbox(key, label string, options ...SelectOption) *Modal {
+	return m.Add(&CheckboxComponent{Type: ComponentTypeCheckbox, Key: key, Label: modalLabel(label), Options: options})
+}
+
+func (m *Modal) DatePicker(key, label string) *Modal {
+	return m.Add(&DatePickerComponent{Type: ComponentTypeDatePicker, Key: key, Label: modalLabel(label)})
+}
+
+func (m *Modal) TimePicker(key, label string) *Modal {
+	return m.Add(&TimePickerComponent{Type: ComponentTypeTimePicker, Key: key, Label: modalLabel(label)})
+}
+
+func (r *Request) SendModal(modal *Modal) error {
+	r.SetMessageType(SendMessageTypeModal)
+	r.msg["modal"] = modal
+	return r.Send()
+}
+
+func (c *Context) ReplyModal(modal *Modal) error {
+	return c.Reply().SendModal(modal)
+}
+
+// DecodeModalSubmit unmarshals the InputJson of a modal submission into T,
+// whose fields are matched to the component keys by their json tag. Fields
+// tagged `webot:"required"` must be submitted with a non-empty value.
+func DecodeModalSubmit[T any](submit ModalSubmit) (T, error) {
+	return decodeInput[T](submit.InputJson)
+}
+
+func decodeInput[T any](input string) (T, error) {
+	var v T
+	values, err := parseModalInput(input)
+	if err != nil {
+		return v, err
+	}
+	if err := checkRequired(reflect.TypeOf(v), values); err != nil {
+		return v, err
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode input: %w", err)
+	}
+	return v, nil
+}
+
+// parseModalInput accepts both an object keyed by component key and a list
+// of {"key": ..., "value": ...} items.
+func parseModalInput(input string) (map[string]json.RawMessage, error) {
+	values := make(map[string]json.RawMessage)
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return values, nil
+	}
+	if strings.HasPrefix(input, "[") {
+		var items []struct {
+			Key   string          `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal([]byte(input), &items); err != nil {
+			return nil, fmt.Errorf("invalid modal input: %w", err)
+		}
+		for _, item := range items {
+			values[item.Key] = item.Value
+		}
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(input), &values); err != nil {
+		return nil, fmt.Errorf("invalid modal input: %w", err)
+	}
+	return values, nil
+}
+
+func checkRequired(t reflect.Type, values map[string]json.RawMessage) error {
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("webot") != "required" {
+			continue
+		}
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" {
+			key = field.Name
+		}
+		switch value := strings.TrimSpace(string(values[key])); value {
+		case "", "null", `""`, "[]", "{}":
+			return fmt.Errorf("%s is required", key)
+		}
+	}
+	return nil
+}
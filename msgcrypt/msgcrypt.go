@@ -0,0 +1,284 @@
+// Package msgcrypt signs, verifies, encrypts and decrypts the callback
+// envelopes of WeCom bots.
+package msgcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrInvalidSignature    = errors.New("msgcrypt: invalid signature")
+	ErrInvalidAESKey       = errors.New("msgcrypt: invalid EncodingAESKey")
+	ErrInvalidReceiverID   = errors.New("msgcrypt: receiver id mismatch")
+	ErrInvalidCiphertext   = errors.New("msgcrypt: invalid ciphertext")
+	ErrInvalidEnvelope     = errors.New("msgcrypt: invalid envelope")
+	ErrUnsupportedProtocol = errors.New("msgcrypt: unsupported protocol")
+)
+
+// Protocol is the format of the envelope carrying the ciphertext.
+type Protocol int
+
+const (
+	XML Protocol = iota + 1
+	JSON
+)
+
+const (
+	blockSize   = 32
+	letterBytes = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+)
+
+// Envelope is a received callback envelope.
+type Envelope struct {
+	ToUserName string `xml:"ToUserName" json:"tousername"`
+	Encrypt    string `xml:"Encrypt" json:"encrypt"`
+	AgentID    string `xml:"AgentID" json:"agentid"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+type xmlReply struct {
+	XMLName   xml.Name `xml:"xml"`
+	Encrypt   cdata    `xml:"Encrypt"`
+	Signature cdata    `xml:"MsgSignature"`
+	Timestamp string   `xml:"TimeStamp"`
+	Nonce     cdata    `xml:"Nonce"`
+}
+
+type jsonReply struct {
+	Encrypt   string `json:"encrypt"`
+	Signature string `json:"msgsignature"`
+	Timestamp int64  `json:"timestamp"`
+	Nonce     string `json:"nonce"`
+}
+
+// Crypt encrypts and decrypts messages with one token and EncodingAESKey.
+// It's safe for concurrent use.
+type Crypt struct {
+	token      string
+	aesKey     []byte
+	block      cipher.Block
+	receiverID string
+	protocol   Protocol
+}
+
+// New decodes and validates the EncodingAESKey. An empty receiverID
+// disables the receiver check on decryption.
+func New(token, encodingAESKey, receiverID string, protocol Protocol) (*Crypt, error) {
+	if protocol != XML && protocol != JSON {
+		return nil, ErrUnsupportedProtocol
+	}
+	aesKey, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAESKey, err)
+	}
+	if len(aesKey) != 32 {
+		return nil, fmt.Errorf("%w: must decode to 32 bytes", ErrInvalidAESKey)
+	}
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAESKey, err)
+	}
+	return &Crypt{token: token, aesKey: aesKey, block: block, receiverID: receiverID, protocol: protocol}, nil
+}
+
+func (c *Crypt) Protocol() Protocol {
+	return c.protocol
+}
+
+// WithReceiverID returns a copy of c checking and encrypting with receiverID.
+func (c *Crypt) WithReceiverID(receiverID string) *Crypt {
+	cc := *c
+	cc.receiverID = receiverID
+	return &cc
+}
+
+// WithProtocol returns a copy of c using protocol for the envelopes.
+func (c *Crypt) WithProtocol(protocol Protocol) (*Crypt, error) {
+	if protocol != XML && protocol != JSON {
+		return nil, ErrUnsupportedProtocol
+	}
+	cc := *c
+	cc.protocol = protocol
+	return &cc, nil
+}
+
+// Sign computes the signature of data.
+func (c *Crypt) Sign(timestamp, nonce, data string) string {
+	parts := [4]string{c.token, timestamp, nonce, data}
+	sort.Strings(parts[:])
+	h := sha1.New()
+	for _, part := range parts {
+		io.WriteString(h, part)
+	}
+	var sum [sha1.Size]byte
+	return hex.EncodeToString(h.Sum(sum[:0]))
+}
+
+// Verify reports whether signature is the signature of data, in constant time.
+func (c *Crypt) Verify(signature, timestamp, nonce, data string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.Sign(timestamp, nonce, data)), []byte(signature)) == 1
+}
+
+// Encrypt encrypts msg and returns the base64 ciphertext.
+func (c *Crypt) Encrypt(msg []byte) (string, error) {
+	random, err := randString(16)
+	if err != nil {
+		return "", err
+	}
+	textLen := 20 + len(msg) + len(c.receiverID)
+	plaintext := make([]byte, 0, textLen+blockSize-textLen%blockSize)
+	plaintext = append(plaintext, random...)
+	plaintext = binary.BigEndian.AppendUint32(plaintext, uint32(len(msg)))
+	plaintext = append(plaintext, msg...)
+	plaintext = append(plaintext, c.receiverID...)
+	plaintext = pad(plaintext)
+	cipher.NewCBCEncrypter(c.block, c.aesKey[:aes.BlockSize]).CryptBlocks(plaintext, plaintext)
+	return base64.StdEncoding.EncodeToString(plaintext), nil
+}
+
+// Decrypt decrypts the base64 ciphertext and checks the receiver id.
+func (c *Crypt) Decrypt(ciphertext string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCiphertext, err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: size is not a multiple of the block size", ErrInvalidCiphertext)
+	}
+	cipher.NewCBCDecrypter(c.block, c.aesKey[:aes.BlockSize]).CryptBlocks(data, data)
+	plaintext, err := unpad(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 20 {
+		return nil, fmt.Errorf("%w: plaintext too short", ErrInvalidCiphertext)
+	}
+	msgLen := binary.BigEndian.Uint32(plaintext[16:20])
+	if uint64(msgLen) > uint64(len(plaintext)-20) {
+		return nil, fmt.Errorf("%w: message length out of range", ErrInvalidCiphertext)
+	}
+	msg := plaintext[20 : 20+msgLen]
+	receiverID := plaintext[20+msgLen:]
+	if c.receiverID != "" && subtle.ConstantTimeCompare(receiverID, []byte(c.receiverID)) != 1 {
+		return nil, ErrInvalidReceiverID
+	}
+	return msg, nil
+}
+
+// VerifyURL verifies and decrypts the echostr of a callback URL verification.
+func (c *Crypt) VerifyURL(signature, timestamp, nonce, echoStr string) ([]byte, error) {
+	if !c.Verify(signature, timestamp, nonce, echoStr) {
+		return nil, ErrInvalidSignature
+	}
+	return c.Decrypt(echoStr)
+}
+
+// ParseEnvelope parses a callback body without verifying it.
+func (c *Crypt) ParseEnvelope(body []byte) (*Envelope, error) {
+	var env Envelope
+	var err error
+	if c.protocol == JSON {
+		err = json.Unmarshal(body, &env)
+	} else {
+		err = xml.Unmarshal(body, &env)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEnvelope, err)
+	}
+	return &env, nil
+}
+
+// DecryptMsg verifies the signature of a callback body and decrypts it.
+func (c *Crypt) DecryptMsg(signature, timestamp, nonce string, body []byte) ([]byte, error) {
+	env, err := c.ParseEnvelope(body)
+	if err != nil {
+		return nil, err
+	}
+	if !c.Verify(signature, timestamp, nonce, env.Encrypt) {
+		return nil, ErrInvalidSignature
+	}
+	return c.Decrypt(env.Encrypt)
+}
+
+// EncryptMsg encrypts msg and returns the signed envelope to respond with.
+func (c *Crypt) EncryptMsg(msg []byte, timestamp, nonce string) ([]byte, error) {
+	ciphertext, err := c.Encrypt(msg)
+	if err != nil {
+		return nil, err
+	}
+	signature := c.Sign(timestamp, nonce, ciphertext)
+	if c.protocol == JSON {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("msgcrypt: invalid timestamp %q", timestamp)
+		}
+		return json.Marshal(&jsonReply{Encrypt: ciphertext, Signature: signature, Timestamp: ts, Nonce: nonce})
+	}
+	return xml.Marshal(&xmlReply{
+		Encrypt:   cdata{ciphertext},
+		Signature: cdata{signature},
+		Timestamp: timestamp,
+		Nonce:     cdata{nonce},
+	})
+}
+
+// randString returns n random letters from crypto/rand, bytes which would
+// bias the distribution are rejected.
+func randString(n int) (string, error) {
+	const maxByte = 256 - 256%len(letterBytes)
+	b := make([]byte, 0, n)
+	buf := make([]byte, n)
+	for len(b) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, c := range buf {
+			if int(c) < maxByte && len(b) < n {
+				b = append(b, letterBytes[int(c)%len(letterBytes)])
+			}
+		}
+	}
+	return string(b), nil
+}
+
+// pad appends the PKCS#7 padding, in place when the capacity of b allows.
+func pad(b []byte) []byte {
+	padding := blockSize - len(b)%blockSize
+	for i := 0; i < padding; i++ {
+		b = append(b, byte(padding))
+	}
+	return b
+}
+
+func unpad(b []byte) ([]byte, error) {
+	if len(b) == 0 || len(b)%blockSize != 0 {
+		return nil, fmt.Errorf("%w: size is not a multiple of the block size", ErrInvalidCiphertext)
+	}
+	padding := int(b[len(b)-1])
+	if padding < 1 || padding > blockSize {
+		return nil, fmt.Errorf("%w: invalid padding", ErrInvalidCiphertext)
+	}
+	for _, c := range b[len(b)-padding:] {
+		if int(c) != padding {
+			return nil, fmt.Errorf("%w: invalid padding", ErrInvalidCiphertext)
+		}
+	}
+	return b[:len(b)-padding], nil
+}
@@ -0,0 +1,38 @@
+package msgcrypt
+
+import (
+	"strings"
+	"testing"
+)
+
+var benchMsg = []byte("<xml><MsgType>text</MsgType><Text><Content><![CDATA[" + strings.Repeat("hello robot ", 40) + "]]></Content></Text></xml>")
+
+func BenchmarkEncryptMsg(b *testing.B) {
+	c := newTestCrypt(b, XML)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.EncryptMsg(benchMsg, testTimestamp, testNonce); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecryptMsg(b *testing.B) {
+	c := newTestCrypt(b, XML)
+	body, err := c.EncryptMsg(benchMsg, testTimestamp, testNonce)
+	if err != nil {
+		b.Fatal(err)
+	}
+	env, err := c.ParseEnvelope(body)
+	if err != nil {
+		b.Fatal(err)
+	}
+	signature := c.Sign(testTimestamp, testNonce, env.Encrypt)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.DecryptMsg(signature, testTimestamp, testNonce, body); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package msgcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+const (
+	testToken          = "QDG6eK"
+	testEncodingAESKey = "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C"
+	testReceiverID     = "wx5823bf96d3bd56c7"
+	testTimestamp      = "1409659813"
+	testNonce          = "1372623149"
+)
+
+func newTestCrypt(tb testing.TB, protocol Protocol) *Crypt {
+	c, err := New(testToken, testEncodingAESKey, testReceiverID, protocol)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return c
+}
+
+func TestNewValidatesKey(t *testing.T) {
+	for _, key := range []string{"", "short", testEncodingAESKey[:42] + "!"} {
+		if _, err := New(testToken, key, "", XML); !errors.Is(err, ErrInvalidAESKey) {
+			t.Fatalf("expected ErrInvalidAESKey for %q, got %v", key, err)
+		}
+	}
+	if _, err := New(testToken, testEncodingAESKey, "", Protocol(0)); !errors.Is(err, ErrUnsupportedProtocol) {
+		t.Fatalf("expected ErrUnsupportedProtocol, got %v", err)
+	}
+}
+
+func TestEncryptDecryptMsg(t *testing.T) {
+	msg := "<xml><MsgType>text</MsgType><Text><Content><![CDATA[hello]]></Content></Text></xml>"
+	for _, protocol := range []Protocol{XML, JSON} {
+		c := newTestCrypt(t, protocol)
+		body, err := c.EncryptMsg([]byte(msg), testTimestamp, testNonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := c.ParseEnvelope(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature := c.Sign(testTimestamp, testNonce, env.Encrypt)
+		decrypted, err := c.DecryptMsg(signature, testTimestamp, testNonce, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(decrypted) != msg {
+			t.Fatalf("decrypted message mismatch: %s", decrypted)
+		}
+		if _, err := c.DecryptMsg(signature[1:]+"0", testTimestamp, testNonce, body); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature, got %v", err)
+		}
+	}
+}
+
+func TestDecryptChecksReceiverID(t *testing.T) {
+	ciphertext, err := newTestCrypt(t, XML).Encrypt([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := New(testToken, testEncodingAESKey, "other", XML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Decrypt(ciphertext); !errors.Is(err, ErrInvalidReceiverID) {
+		t.Fatalf("expected ErrInvalidReceiverID, got %v", err)
+	}
+}
+
+func TestUnpadRejectsInvalidPadding(t *testing.T) {
+	for _, padding := range []byte{0, 33, 255} {
+		plaintext := make([]byte, 32)
+		plaintext[31] = padding
+		if _, err := unpad(plaintext); err == nil {
+			t.Fatalf("expected error for padding %d", padding)
+		}
+	}
+	plaintext := make([]byte, 32)
+	plaintext[30], plaintext[31] = 1, 2
+	if _, err := unpad(plaintext); err == nil {
+		t.Fatal("expected error for inconsistent padding")
+	}
+}
+
+// FuzzDecryptMsg feeds arbitrary plaintexts, correctly encrypted and signed,
+// to the decoder, which must return an error instead of panicking.
+func FuzzDecryptMsg(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(make([]byte, 32))
+	f.Add([]byte("0123456789abcdef\xff\xff\xff\xffhello"))
+	f.Add([]byte("0123456789abcdef\x00\x00\x00\x05hellowx5823bf96d3bd56c7"))
+	c := newTestCrypt(f, XML)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		plaintext := make([]byte, (len(data)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+		copy(plaintext, data)
+		cipher.NewCBCEncrypter(c.block, c.aesKey[:aes.BlockSize]).CryptBlocks(plaintext, plaintext)
+		encrypt := base64.StdEncoding.EncodeToString(plaintext)
+		signature := c.Sign(testTimestamp, testNonce, encrypt)
+		body := []byte("<xml><Encrypt><![CDATA[" + encrypt + "]]></Encrypt></xml>")
+		c.DecryptMsg(signature, testTimestamp, testNonce, body)
+		c.VerifyURL(signature, testTimestamp, testNonce, encrypt)
+	})
+}
@@ -0,0 +1,56 @@
+package webot
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strings"
+)
+
+// Protocol is the envelope format of the callbacks.
+type Protocol int
+
+const (
+	// ProtocolAuto detects the protocol from the Content-Type header and the body.
+	ProtocolAuto Protocol = iota
+	ProtocolXML
+	ProtocolJSON
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolXML:
+		return "xml"
+	case ProtocolJSON:
+		return "json"
+	default:
+		return "auto"
+	}
+}
+
+// SetProtocol sets the protocol of the callbacks, defaults to ProtocolAuto.
+func (s *Server) SetProtocol(protocol Protocol) *Server {
+	s.protocol = protocol
+	return s
+}
+
+func (s *Server) detectProtocol(r *http.Request, body []byte) Protocol {
+	if s.protocol != ProtocolAuto {
+		return s.protocol
+	}
+	if r != nil && strings.Contains(r.Header.Get("Content-Type"), "json") {
+		return ProtocolJSON
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		return ProtocolJSON
+	}
+	return ProtocolXML
+}
+
+func unmarshalMessage(protocol Protocol, data []byte, msg *CallbackMessage) error {
+	if protocol == ProtocolJSON {
+		return json.Unmarshal(data, msg)
+	}
+	return xml.Unmarshal(data, msg)
+}
@@ -0,0 +1,111 @@
+package webot
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+func TestDetectProtocol(t *testing.T) {
+	cases := []struct {
+		name        string
+		protocol    Protocol
+		contentType string
+		body        string
+		want        Protocol
+	}{
+		{"json content type", ProtocolAuto, "application/json; charset=utf-8", "<xml></xml>", ProtocolJSON},
+		{"json body", ProtocolAuto, "text/plain", ` {"encrypt":"x"}`, ProtocolJSON},
+		{"xml body", ProtocolAuto, "", "<xml><Encrypt>x</Encrypt></xml>", ProtocolXML},
+		{"forced xml", ProtocolXML, "application/json", `{"encrypt":"x"}`, ProtocolXML},
+		{"forced json", ProtocolJSON, "", "<xml></xml>", ProtocolJSON},
+	}
+	for _, c := range cases {
+		s := newTestServer(t).SetProtocol(c.protocol)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		r.Header.Set("Content-Type", c.contentType)
+		if got := s.detectProtocol(r, []byte(c.body)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+const testJSONAttachmentMessage = `{
+	"webhook_url": "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc",
+	"chatid": "wrkSFfCgAALFgnrSsWU38puiv4yvExuw",
+	"postid": "bpkSFfCgAAWeiHos2p6lJbG3_F2xxxxx",
+	"chattype": "group",
+	"msgid": "abcdabcdabcd",
+	"msgtype": "attachment",
+	"aibotid": "aibot1",
+	"response_url": "https://qyapi.weixin.qq.com/cgi-bin/aibot/response?response_code=xyz",
+	"from": {"userid": "zhangsan", "name": "张三", "alias": "jackzhang"},
+	"attachment": {
+		"callbackid": "deploy",
+		"actions": [
+			{"name": "env", "value": "prod", "type": "button"},
+			{"name": "confirm", "value": "yes", "type": "button"}
+		]
+	}
+}`
+
+func TestJSONCallback(t *testing.T) {
+	want := CallbackMessage{
+		Attachment: &Attachment{
+			CallbackId: "deploy",
+			Actions: []Actions{
+				{Name: "env", Value: "prod", Type: "button"},
+				{Name: "confirm", Value: "yes", Type: "button"},
+			},
+		},
+		CallbackMessageCommonItem: CallbackMessageCommonItem{
+			WebhookUrl:  "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc",
+			ChatId:      "wrkSFfCgAALFgnrSsWU38puiv4yvExuw",
+			PostId:      "bpkSFfCgAAWeiHos2p6lJbG3_F2xxxxx",
+			ChatType:    "group",
+			MsgId:       "abcdabcdabcd",
+			MsgType:     CallbackMessageTypeAttachment,
+			AibotId:     "aibot1",
+			ResponseUrl: "https://qyapi.weixin.qq.com/cgi-bin/aibot/response?response_code=xyz",
+			From:        From{UserId: "zhangsan", Name: "张三", Alias: "jackzhang"},
+		},
+	}
+
+	t.Run("DecryptMsg detects the body", func(t *testing.T) {
+		s := newTestServer(t)
+		r := newTestCallback(t, msgcrypt.JSON, []byte(testJSONAttachmentMessage))
+		body, err := io.ReadAll(r.Body)
+		tests.AssertNoError(t, err)
+		query := r.URL.Query()
+		msg, err := s.DecryptMsg(query.Get("msg_signature"), query.Get("timestamp"), query.Get("nonce"), body)
+		tests.AssertNoError(t, err)
+		if !reflect.DeepEqual(*msg, want) {
+			t.Fatalf("got %+v\nwant %+v", *msg, want)
+		}
+	})
+
+	t.Run("ServeHTTP detects the content type", func(t *testing.T) {
+		s := newTestServer(t)
+		var got CallbackMessage
+		s.HandleMessage(func(ctx *Context) error {
+			got = ctx.Message
+			return nil
+		})
+		r := newTestCallback(t, msgcrypt.JSON, []byte(testJSONAttachmentMessage))
+		r.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %+v\nwant %+v", got, want)
+		}
+	})
+}
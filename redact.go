@@ -0,0 +1,134 @@
+package webot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const redactedMask = "******"
+
+// defaultRedactedParams are the url query parameters which carry secrets.
+var defaultRedactedParams = []string{"key", "token", "access_token", "response_code", "msg_signature", "echostr"}
+
+// Redactor masks webhook keys, tokens and message fields in dumps, errors
+// and logs.
+type Redactor struct {
+	params *regexp.Regexp
+	fields *regexp.Regexp
+}
+
+// NewRedactor create a Redactor which masks the secret url query parameters
+// and the given message fields. Fields are matched case-insensitively against
+// both JSON keys and XML elements, e.g. "content" masks "content" in JSON
+// bodies and <Content> in XML bodies.
+func NewRedactor(fields ...string) *Redactor {
+	r := &Redactor{params: paramsRegexp(defaultRedactedParams)}
+	if len(fields) > 0 {
+		r.fields = fieldsRegexp(fields)
+	}
+	return r
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return strings.Join(quoted, "|")
+}
+
+func paramsRegexp(params []string) *regexp.Regexp {
+	// \u0026 is how encoding/json escapes '&'.
+	return regexp.MustCompile(`(?i)((?:[?&]|\\u0026)(?:` + quoteAll(params) + `)=)[^&\s"'<\\\]]*`)
+}
+
+func fieldsRegexp(fields []string) *regexp.Regexp {
+	names := quoteAll(fields)
+	return regexp.MustCompile(`(?is)` +
+		`("(?:` + names + `)"\s*:\s*)(?:"(?:[^"\\]|\\.)*"|\[[^\]]*\]|[^,}\s]+)` +
+		`|(<(` + names + `)>)(.*?)(</(?:` + names + `)>)`)
+}
+
+// Redact returns s with the secrets masked.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	s = r.params.ReplaceAllString(s, "${1}"+redactedMask)
+	if r.fields != nil {
+		s = r.fields.ReplaceAllStringFunc(s, func(m string) string {
+			sub := r.fields.FindStringSubmatch(m)
+			if sub[1] != "" {
+				return sub[1] + `"` + redactedMask + `"`
+			}
+			return sub[2] + redactedMask + sub[5]
+		})
+	}
+	return s
+}
+
+// redactedError masks the secrets in the message of err.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (r *Redactor) redactError(err error) error {
+	if err == nil || r == nil {
+		return err
+	}
+	return &redactedError{err: err, msg: r.Redact(err.Error())}
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactLogger masks the secrets in every line written to the Logger.
+type redactLogger struct {
+	l Logger
+	r *Redactor
+}
+
+func newRedactLogger(l Logger, r *Redactor) Logger {
+	return &redactLogger{l: l, r: r}
+}
+
+func (l *redactLogger) Errorf(format string, v ...interface{}) {
+	l.l.Errorf("%s", l.r.Redact(fmt.Sprintf(format, v...)))
+}
+
+func (l *redactLogger) Warnf(format string, v ...interface{}) {
+	l.l.Warnf("%s", l.r.Redact(fmt.Sprintf(format, v...)))
+}
+
+func (l *redactLogger) Debugf(format string, v ...interface{}) {
+	l.l.Debugf("%s", l.r.Redact(fmt.Sprintf(format, v...)))
+}
+
+func (l *redactLogger) Infof(format string, v ...interface{}) {
+	l.l.Infof("%s", l.r.Redact(fmt.Sprintf(format, v...)))
+}
+
+func (l *redactLogger) With(args ...any) Logger {
+	fl, ok := l.l.(FieldLogger)
+	if !ok {
+		return l
+	}
+	return &redactLogger{l: fl.With(args...), r: l.r}
+}
+
+// dumpWriter writes the dumps of the req client to the Logger.
+type dumpWriter struct {
+	client *Client
+}
+
+func (w dumpWriter) Write(p []byte) (int, error) {
+	w.client.log.Infof("%s", strings.TrimRight(w.client.redactor.Redact(string(p)), "\n"))
+	return len(p), nil
+}
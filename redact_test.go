@@ -0,0 +1,71 @@
+package webot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	cases := []struct {
+		name   string
+		fields []string
+		in     string
+		want   string
+	}{
+		{
+			"dump request line", nil,
+			"POST /cgi-bin/webhook/send?key=693a91f6-7xxx&debug=1 HTTP/1.1",
+			"POST /cgi-bin/webhook/send?key=******&debug=1 HTTP/1.1",
+		},
+		{
+			"several params", nil,
+			"https://example.com/?token=abc&KEY=def&echostr=ghi",
+			"https://example.com/?token=******&KEY=******&echostr=******",
+		},
+		{
+			"json body", nil,
+			`{"webhook_url":"https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc","response_url":"https://x/?a=1&response_code=def"}`,
+			`{"webhook_url":"https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=******","response_url":"https://x/?a=1&response_code=******"}`,
+		},
+		{
+			"xml cdata", nil,
+			"<WebhookUrl><![CDATA[https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc]]></WebhookUrl>",
+			"<WebhookUrl><![CDATA[https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=******]]></WebhookUrl>",
+		},
+		{
+			"unrelated param", nil,
+			"https://example.com/?monkey=1&keys=2",
+			"https://example.com/?monkey=1&keys=2",
+		},
+		{
+			"json fields", []string{"content", "mentioned_list"},
+			`{"text":{"content":"deploy \"prod\"","mentioned_list":["a","b"]},"msgtype":"text"}`,
+			`{"text":{"content":"******","mentioned_list":"******"},"msgtype":"text"}`,
+		},
+		{
+			"xml fields", []string{"content"},
+			"<Text><Content><![CDATA[line1\nline2]]></Content></Text><MsgType>text</MsgType>",
+			"<Text><Content>******</Content></Text><MsgType>text</MsgType>",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := NewRedactor(c.fields...).Redact(c.in); got != c.want {
+				t.Errorf("got  %s\nwant %s", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	cause := errors.New(`Post "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=abc": dial tcp: timeout`)
+	err := NewRedactor().redactError(fmt.Errorf("send: %w", cause))
+	if strings.Contains(err.Error(), "abc") {
+		t.Fatalf("key leaked in %q", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("redacted error does not wrap the cause")
+	}
+}
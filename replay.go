@@ -0,0 +1,53 @@
+package webot
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// NonceStore records the nonces of accepted callbacks, any DedupStore can be
+// used as a NonceStore.
+type NonceStore interface {
+	// Add records key for ttl and reports whether it was not recorded yet.
+	Add(key string, ttl time.Duration) (bool, error)
+}
+
+// EnableReplayGuard rejects callbacks whose timestamp is more than window
+// away from now, or whose nonce was already used within the window. A nil
+// store means an in-memory store.
+func (s *Server) EnableReplayGuard(window time.Duration, store NonceStore) *Server {
+	if store == nil {
+		store = NewMemoryDedupStore()
+	}
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	s.replayWindow = window
+	s.nonceStore = store
+	return s
+}
+
+func (s *Server) checkReplay(timestamp, nonce string) error {
+	if s.nonceStore == nil {
+		return nil
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > s.replayWindow || d < -s.replayWindow {
+		return fmt.Errorf("timestamp %s is out of the replay window", timestamp)
+	}
+	if nonce == "" {
+		return fmt.Errorf("empty nonce")
+	}
+	added, err := s.nonceStore.Add("nonce/"+timestamp+"/"+nonce, 2*s.replayWindow)
+	if err != nil {
+		return fmt.Errorf("failed to check nonce: %w", err)
+	}
+	if !added {
+		return fmt.Errorf("nonce %s was already used", nonce)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package webot
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+func TestCheckReplay(t *testing.T) {
+	const window = time.Minute
+	ts := func(d time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(d).Unix(), 10)
+	}
+	now := ts(0)
+	cases := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		ok        bool
+	}{
+		{"now", now, "n1", true},
+		{"reused nonce", now, "n1", false},
+		{"same nonce other timestamp", ts(-time.Second), "n1", true},
+		{"past inside window", ts(-window + 5*time.Second), "n2", true},
+		{"future inside window", ts(window - 5*time.Second), "n3", true},
+		{"past outside window", ts(-window - 5*time.Second), "n4", false},
+		{"future outside window", ts(window + 5*time.Second), "n5", false},
+		{"empty nonce", now, "", false},
+		{"invalid timestamp", "abc", "n6", false},
+		{"empty timestamp", "", "n7", false},
+	}
+	s := newTestServer(t).EnableReplayGuard(window, nil)
+	for _, c := range cases {
+		err := s.checkReplay(c.timestamp, c.nonce)
+		if ok := err == nil; ok != c.ok {
+			t.Errorf("%s: checkReplay(%q, %q) = %v, want ok=%v", c.name, c.timestamp, c.nonce, err, c.ok)
+		}
+	}
+}
+
+func TestCheckReplayDisabled(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 2; i++ {
+		if err := s.checkReplay("0", ""); err != nil {
+			t.Fatalf("replay guard is disabled but got %v", err)
+		}
+	}
+}
+
+func TestReplayedCallbackRejected(t *testing.T) {
+	s := newTestServer(t).EnableReplayGuard(time.Minute, nil)
+	msg := tests.GetTestFileContent(t, "msg-text.xml")
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	for i, want := range []int{http.StatusOK, http.StatusForbidden} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, newTestCallbackAt(t, msgcrypt.XML, msg, now, testNonce))
+		if rec.Code != want {
+			t.Fatalf("delivery %d: status = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+	// newTestCallback uses a fixed timestamp, far outside the window.
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, msg))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
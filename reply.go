@@ -0,0 +1,215 @@
+package webot
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imroc/webot/msgcrypt"
+)
+
+// ReplyMessage is a reply sent in the HTTP response of a callback.
+type ReplyMessage struct {
+	MsgType      SendMessageType      `json:"msgtype"`
+	Text         *TextMessage         `json:"text,omitempty"`
+	Markdown     *MarkdownMessage     `json:"markdown,omitempty"`
+	TemplateCard *TemplateCardMessage `json:"template_card,omitempty"`
+	Stream       *StreamReply         `json:"stream,omitempty"`
+}
+
+// xmlReplyMessage is the reply body of the XML protocol, which only
+// supports text and markdown replies.
+type xmlReplyMessage struct {
+	XMLName  xml.Name                 `xml:"xml"`
+	MsgType  SendMessageType          `xml:"MsgType"`
+	Text     *xmlReplyTextMessage     `xml:"Text,omitempty"`
+	Markdown *xmlReplyMarkdownMessage `xml:"Markdown,omitempty"`
+}
+
+type xmlReplyTextMessage struct {
+	Content             string   `xml:"Content"`
+	MentionedList       []string `xml:"MentionedList>Item,omitempty"`
+	MentionedMobileList []string `xml:"MentionedMobileList>Item,omitempty"`
+}
+
+type xmlReplyMarkdownMessage struct {
+	Content string `xml:"Content"`
+}
+
+// supportsXMLReply reports whether msg can be written as an XML reply.
+func supportsXMLReply(msg *ReplyMessage) bool {
+	switch msg.MsgType {
+	case SendMessageTypeText:
+		return msg.Text != nil
+	case SendMessageTypeMarkdown:
+		return msg.Markdown != nil
+	default:
+		return false
+	}
+}
+
+// marshalReply encodes msg as the plaintext reply body of the protocol.
+func marshalReply(protocol msgcrypt.Protocol, msg *ReplyMessage) ([]byte, error) {
+	if protocol == msgcrypt.JSON {
+		return json.Marshal(msg)
+	}
+	if !supportsXMLReply(msg) {
+		return nil, fmt.Errorf("unsupported xml reply message type %q", msg.MsgType)
+	}
+	reply := &xmlReplyMessage{MsgType: msg.MsgType}
+	if msg.Text != nil {
+		reply.Text = &xmlReplyTextMessage{
+			Content:             msg.Text.Content,
+			MentionedList:       msg.Text.MentionedList,
+			MentionedMobileList: msg.Text.MentionedMobileList,
+		}
+	}
+	if msg.Markdown != nil {
+		reply.Markdown = &xmlReplyMarkdownMessage{Content: msg.Markdown.Content}
+	}
+	return xml.Marshal(reply)
+}
+
+// passiveReply holds the reply of a callback until the HTTP response is written.
+type passiveReply struct {
+	mu       sync.Mutex
+	msg      *ReplyMessage
+	closed   bool
+	ready    chan struct{}
+	protocol msgcrypt.Protocol
+}
+
+func newPassiveReply(protocol msgcrypt.Protocol) *passiveReply {
+	return &passiveReply{ready: make(chan struct{}), protocol: protocol}
+}
+
+// set stores msg as the reply, it reports false if the response is already
+// written, another reply is stored or the protocol can't carry msg.
+func (p *passiveReply) set(msg *ReplyMessage) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.msg != nil {
+		return false
+	}
+	if p.protocol == msgcrypt.XML && !supportsXMLReply(msg) {
+		return false
+	}
+	p.msg = msg
+	close(p.ready)
+	return true
+}
+
+// close prevents further replies and returns the stored one.
+func (p *passiveReply) close() *ReplyMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	return p.msg
+}
+
+// SetPassiveReplyTimeout sets how long the callback response waits for the
+// handlers to reply, defaults to 3 seconds. Replies made later are sent
+// through the webhook instead.
+func (s *Server) SetPassiveReplyTimeout(timeout time.Duration) *Server {
+	s.passiveReplyTimeout = timeout
+	return s
+}
+
+// Respond replies in the HTTP response of the callback when possible, that
+// is in sync mode, before the passive reply timeout, if no other reply was
+// made and, for XML callbacks, for text and markdown replies. Otherwise the
+// reply is sent through the webhook.
+func (c *Context) Respond(msg *ReplyMessage) error {
+	if c.reply != nil && c.reply.set(msg) {
+		return nil
+	}
+	r := c.Reply()
+	switch msg.MsgType {
+	case SendMessageTypeText:
+		return r.SendText(msg.Text)
+	case SendMessageTypeMarkdown:
+		return r.SendMarkdown(msg.Markdown)
+	case SendMessageTypeTemplateCard:
+		return r.SendTemplateCard(msg.TemplateCard)
+	default:
+		return fmt.Errorf("unsupported reply message type %q", msg.MsgType)
+	}
+}
+
+func (c *Context) RespondText(content string, mentionedList ...string) error {
+	return c.Respond(&ReplyMessage{
+		MsgType: SendMessageTypeText,
+		Text:    &TextMessage{Content: content, MentionedList: mentionedList},
+	})
+}
+
+func (c *Context) RespondMarkdown(content string) error {
+	return c.Respond(&ReplyMessage{
+		MsgType:  SendMessageTypeMarkdown,
+		Markdown: &MarkdownMessage{Content: content},
+	})
+}
+
+func (c *Context) RespondCard(card *TemplateCardMessage) error {
+	return c.Respond(&ReplyMessage{
+		MsgType:      SendMessageTypeTemplateCard,
+		TemplateCard: card,
+	})
+}
+
+// dispatchWithReply runs the handlers and writes the reply they make within
+// the passive reply timeout, encrypted, as the HTTP response.
+func (s *Server) dispatchWithReply(w http.ResponseWriter, msg CallbackMessage, received time.Time, nonce string, crypt *msgcrypt.Crypt) {
+	reply := newPassiveReply(crypt.Protocol())
+	done := make(chan struct{})
+	s.inflight.add()
+	go func() {
+		defer s.inflight.done()
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				s.msgLogger(&msg).Errorf("handler panic: %v", r)
+			}
+		}()
+		s.dispatch(msg, reply)
+	}()
+
+	timer := time.NewTimer(s.passiveReplyTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-reply.ready:
+	case <-timer.C:
+		s.msgLogger(&msg).Warnf("handlers are still running after %s", s.passiveReplyTimeout)
+	}
+	if replyMsg := reply.close(); replyMsg != nil {
+		s.writeReply(w, msg, received, replyMsg, nonce, crypt)
+	}
+}
+
+// writeReply writes the encrypted reply as the HTTP response, received is
+// when the callback arrived.
+func (s *Server) writeReply(w http.ResponseWriter, msg CallbackMessage, received time.Time, replyMsg *ReplyMessage, nonce string, crypt *msgcrypt.Crypt) {
+	data, err := marshalReply(crypt.Protocol(), replyMsg)
+	if err != nil {
+		s.msgLogger(&msg).Errorf("failed to marshal reply: %v", err)
+		s.auditReply(&msg, received, replyMsg, err)
+		return
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	body, err := crypt.EncryptMsg(data, timestamp, nonce)
+	if err != nil {
+		s.msgLogger(&msg).Errorf("failed to encrypt reply: %v", err)
+		s.auditReply(&msg, received, replyMsg, err)
+		return
+	}
+	if crypt.Protocol() == msgcrypt.JSON {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	_, err = w.Write(body)
+	s.auditReply(&msg, received, replyMsg, err)
+}
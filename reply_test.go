@@ -0,0 +1,74 @@
+package webot
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+const testJSONTextMessage = `{"webhook_url":"https://qyapi.weixin.qq.com/xxxxxxx","chatid":"wrkSFfCgAALFgnrSsWU38puiv4yvExuw","chattype":"single","msgid":"abcdabcdabcd","msgtype":"text","from":{"userid":"zhangsan","name":"张三","alias":"jackzhang"},"text":{"content":"@RobotA hello robot"}}`
+
+func TestPassiveReply(t *testing.T) {
+	for _, protocol := range []msgcrypt.Protocol{msgcrypt.XML, msgcrypt.JSON} {
+		s := newTestServer(t)
+		s.HandleTextMessage(func(ctx *Context, text Text) error {
+			return ctx.RespondText("pong", "zhangsan")
+		})
+		msg := tests.GetTestFileContent(t, "msg-text.xml")
+		if protocol == msgcrypt.JSON {
+			msg = []byte(testJSONTextMessage)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, newTestCallback(t, protocol, msg))
+		data := decryptTestResponse(t, protocol, rec)
+
+		var reply ReplyMessage
+		if protocol == msgcrypt.JSON {
+			tests.AssertNoError(t, json.Unmarshal(data, &reply))
+		} else {
+			var xmlReply xmlReplyMessage
+			tests.AssertNoError(t, xml.Unmarshal(data, &xmlReply))
+			reply.MsgType = xmlReply.MsgType
+			if xmlReply.Text != nil {
+				reply.Text = &TextMessage{Content: xmlReply.Text.Content, MentionedList: xmlReply.Text.MentionedList}
+			}
+		}
+		if reply.MsgType != SendMessageTypeText || reply.Text == nil || reply.Text.Content != "pong" {
+			t.Fatalf("%v: unexpected reply %s", protocol, data)
+		}
+		if len(reply.Text.MentionedList) != 1 || reply.Text.MentionedList[0] != "zhangsan" {
+			t.Fatalf("%v: unexpected mentioned list in %s", protocol, data)
+		}
+	}
+}
+
+func TestWelcomeMessage(t *testing.T) {
+	s := newTestServer(t)
+	s.SetWelcomeMessage("**welcome**")
+	msg := `{"webhook_url":"https://qyapi.weixin.qq.com/xxxxxxx","chatid":"wrkSFfCgAALFgnrSsWU38puiv4yvExuw","chattype":"single","msgid":"abcdabcdabce","msgtype":"event","from":{"userid":"zhangsan"},"event":{"eventtype":"enter_chat"}}`
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.JSON, []byte(msg)))
+	var reply ReplyMessage
+	tests.AssertNoError(t, json.Unmarshal(decryptTestResponse(t, msgcrypt.JSON, rec), &reply))
+	if reply.MsgType != SendMessageTypeMarkdown || reply.Markdown == nil || reply.Markdown.Content != "**welcome**" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+}
+
+func TestXMLReplyBody(t *testing.T) {
+	data, err := marshalReply(msgcrypt.XML, &ReplyMessage{
+		MsgType:  SendMessageTypeMarkdown,
+		Markdown: &MarkdownMessage{Content: "**hi**"},
+	})
+	tests.AssertNoError(t, err)
+	if want := "<xml><MsgType>markdown</MsgType><Markdown><Content>**hi**</Content></Markdown></xml>"; string(data) != want {
+		t.Fatalf("got %s, want %s", data, want)
+	}
+	if _, err := marshalReply(msgcrypt.XML, &ReplyMessage{MsgType: SendMessageTypeTemplateCard}); err == nil {
+		t.Fatal("expected an error for a template card xml reply")
+	}
+}
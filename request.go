@@ -6,19 +6,25 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/imroc/req/v3"
 )
 
 type Request struct {
 	*req.Request
+	client     *Client
 	msg        map[string]any
 	webhookUrl string
+	// robotName and msgId tag the audit record of replies made from a Context.
+	robotName string
+	msgId     string
 }
 
 func (c *Client) NewRequest(webhookUrl string) *Request {
 	return &Request{
 		Request:    c.client.R(),
+		client:     c,
 		webhookUrl: webhookUrl,
 		msg:        make(map[string]any),
 	}
@@ -48,6 +54,9 @@ func (r *Request) Reply(msg CallbackMessageCommonItem) *Request {
 }
 
 func (r *Request) Send() error {
+	r.client.pending.add()
+	defer r.client.pending.done()
+	start := time.Now()
 	resp := &Response{}
 	res, err := r.
 		SetBodyJsonMarshal(r.msg).
@@ -55,12 +64,15 @@ func (r *Request) Send() error {
 		SetSuccessResult(resp).
 		Post(r.webhookUrl)
 	if err != nil {
+		err = r.client.redactor.redactError(err)
+		r.client.auditSend(r, r.webhookUrl, r.msg, start, nil, err)
 		return err
 	}
 	if !res.IsSuccessState() || resp.Errcode != 0 {
-		return fmt.Errorf("bad response:\n%s", res.Dump())
+		err = fmt.Errorf("bad response:\n%s", r.client.redactor.Redact(res.Dump()))
 	}
-	return nil
+	r.client.auditSend(r, r.webhookUrl, r.msg, start, resp, err)
+	return err
 }
 
 func (r *Request) SendFileContent(filename string, content []byte) (err error) {
@@ -77,6 +89,10 @@ func (r *Request) SendFileContent(filename string, content []byte) (err error) {
 }
 
 func (r *Request) Upload(filename string, data []byte) (resp *UploadResponse, err error) {
+	r.client.pending.add()
+	defer r.client.pending.done()
+	start := time.Now()
+	payload := map[string]any{"filename": filename, "filelength": len(data)}
 	uploadUrl := strings.ReplaceAll(r.webhookUrl, "webhook/send", "webhook/upload_media")
 	resp = &UploadResponse{}
 	cd := new(req.ContentDisposition)
@@ -94,12 +110,14 @@ func (r *Request) Upload(filename string, data []byte) (resp *UploadResponse, er
 		SetSuccessResult(resp).
 		Post(uploadUrl)
 	if err != nil {
+		err = r.client.redactor.redactError(err)
+		r.client.auditSend(r, uploadUrl, payload, start, nil, err)
 		return
 	}
 	if !res.IsSuccessState() || resp.Errcode != 0 {
-		err = fmt.Errorf("bad response:\n%s", res.Dump())
-		return
+		err = fmt.Errorf("bad response:\n%s", r.client.redactor.Redact(res.Dump()))
 	}
+	r.client.auditSend(r, uploadUrl, payload, start, &resp.Response, err)
 	return
 }
 
@@ -114,3 +132,9 @@ func (r *Request) SendText(text *TextMessage) (err error) {
 	r.msg["text"] = text
 	return r.Send()
 }
+
+func (r *Request) SendTemplateCard(card *TemplateCardMessage) (err error) {
+	r.SetMessageType(SendMessageTypeTemplateCard)
+	r.msg["template_card"] = card
+	return r.Send()
+}
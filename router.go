@@ -0,0 +1,178 @@
+package webot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Middleware wraps the handling of every received message.
+type Middleware func(next MessageHandler) MessageHandler
+
+// Router hosts several bots, each with its own credentials and handlers,
+// routing callbacks by URL path (/callback/{bot} by default) or by header.
+// The bots share the router's Client, logger, middlewares, dedup store and
+// audit sink.
+type Router struct {
+	mu          sync.RWMutex
+	bots        map[string]*Server
+	client      *Client
+	log         Logger
+	middlewares []Middleware
+	dedupStore  DedupStore
+	dedupTTL    time.Duration
+	audit       AuditSink
+	pathPrefix  string
+	routeHeader string
+}
+
+func NewRouter() *Router {
+	return &Router{
+		bots:       make(map[string]*Server),
+		client:     NewClient(),
+		log:        createDefaultLogger(),
+		pathPrefix: "/callback/",
+	}
+}
+
+func (r *Router) GetClient() *Client {
+	return r.client
+}
+
+func (r *Router) SetClient(client *Client) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = client
+	for _, bot := range r.bots {
+		bot.SetClient(client)
+	}
+	return r
+}
+
+func (r *Router) SetLogger(logger Logger) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = logger
+	for _, bot := range r.bots {
+		bot.SetLogger(logger)
+	}
+	return r
+}
+
+// Use adds middlewares that run for every bot, before the bot's own middlewares.
+func (r *Router) Use(middlewares ...Middleware) *Router {
+	r.mu.Lock()
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.mu.Unlock()
+	return r
+}
+
+// EnableDedup enables deduplication with a store shared by all bots,
+// see Server.EnableDedup.
+func (r *Router) EnableDedup(store DedupStore, ttl time.Duration) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if store == nil {
+		store = NewMemoryDedupStore()
+	}
+	r.dedupStore, r.dedupTTL = store, ttl
+	for _, bot := range r.bots {
+		bot.EnableDedup(store, ttl)
+	}
+	return r
+}
+
+// SetAuditSink sets the audit sink of all bots and the shared client.
+func (r *Router) SetAuditSink(sink AuditSink) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.audit = sink
+	r.client.SetAuditSink(sink)
+	for _, bot := range r.bots {
+		bot.SetAuditSink(sink)
+	}
+	return r
+}
+
+// SetPathPrefix sets the path prefix followed by the bot name, defaults to "/callback/".
+func (r *Router) SetPathPrefix(prefix string) *Router {
+	r.mu.Lock()
+	r.pathPrefix = prefix
+	r.mu.Unlock()
+	return r
+}
+
+// SetRouteHeader routes callbacks by the value of the header instead of the
+// path, requests without the header still fall back to the path.
+func (r *Router) SetRouteHeader(name string) *Router {
+	r.mu.Lock()
+	r.routeHeader = name
+	r.mu.Unlock()
+	return r
+}
+
+// AddBot registers a bot under name and returns its Server to register
+// handlers on, it returns an error if a bot is already registered under name.
+func (r *Router) AddBot(name, token, encodingAeskey, robotName string) (*Server, error) {
+	bot, err := NewServer(token, encodingAeskey, robotName)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.bots[name]; ok {
+		return nil, fmt.Errorf("bot %q already registered", name)
+	}
+	bot.SetClient(r.client).SetLogger(r.log)
+	bot.router = r
+	if r.dedupStore != nil {
+		bot.EnableDedup(r.dedupStore, r.dedupTTL)
+	}
+	if r.audit != nil {
+		bot.SetAuditSink(r.audit)
+	}
+	r.bots[name] = bot
+	return bot, nil
+}
+
+// Bot returns the bot registered under name, or nil.
+func (r *Router) Bot(name string) *Server {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.bots[name]
+}
+
+func (r *Router) botName(req *http.Request) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.routeHeader != "" {
+		if name := req.Header.Get(r.routeHeader); name != "" {
+			return name
+		}
+	}
+	if !strings.HasPrefix(req.URL.Path, r.pathPrefix) {
+		return ""
+	}
+	return strings.Trim(strings.TrimPrefix(req.URL.Path, r.pathPrefix), "/")
+}
+
+// ServeHTTP implements http.Handler, mount it on a mux at the path prefix,
+// e.g. mux.Handle("/callback/", router).
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := r.botName(req)
+	bot := r.Bot(name)
+	if bot == nil {
+		r.log.Errorf("no bot found for %s", req.URL.Path)
+		http.NotFound(w, req)
+		return
+	}
+	bot.CallbackHandler(w, req)
+}
+
+func (r *Router) getMiddlewares() []Middleware {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.middlewares
+}
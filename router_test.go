@@ -0,0 +1,113 @@
+package webot
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+// newTestRouter returns a router with the bots "a" and "b", whose text
+// handlers record the name of the bot which handled the message.
+func newTestRouter(t *testing.T) (*Router, func() []string) {
+	var mu sync.Mutex
+	var handled []string
+	router := NewRouter().SetLogger(NewLogger(io.Discard, "", log.LstdFlags))
+	for _, name := range []string{"a", "b"} {
+		name := name
+		bot, err := router.AddBot(name, testToken, testEncodingAESKey, "RobotA")
+		tests.AssertNoError(t, err)
+		bot.HandleTextMessage(func(ctx *Context, text Text) error {
+			mu.Lock()
+			handled = append(handled, name)
+			mu.Unlock()
+			return nil
+		})
+	}
+	return router, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), handled...)
+	}
+}
+
+func newTestRouterCallback(t *testing.T, path string) *http.Request {
+	req := newTestCallback(t, msgcrypt.XML, tests.GetTestFileContent(t, "msg-text.xml"))
+	req.URL.Path = path
+	return req
+}
+
+func TestRouterRouting(t *testing.T) {
+	cases := []struct {
+		name    string
+		path    string
+		header  string
+		status  int
+		handled []string
+	}{
+		{"path", "/callback/a", "", http.StatusOK, []string{"a"}},
+		{"path with trailing slash", "/callback/b/", "", http.StatusOK, []string{"b"}},
+		{"header", "/callback/a", "b", http.StatusOK, []string{"b"}},
+		{"unknown bot", "/callback/c", "", http.StatusNotFound, nil},
+		{"unknown bot in header", "/callback/a", "c", http.StatusNotFound, nil},
+		{"outside prefix", "/other/a", "", http.StatusNotFound, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			router, handled := newTestRouter(t)
+			router.SetRouteHeader("X-Bot")
+			req := newTestRouterCallback(t, c.path)
+			if c.header != "" {
+				req.Header.Set("X-Bot", c.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != c.status {
+				t.Fatalf("status = %d, want %d", rec.Code, c.status)
+			}
+			if got := handled(); !reflect.DeepEqual(got, c.handled) {
+				t.Fatalf("handled by %q, want %q", got, c.handled)
+			}
+		})
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	var order []string
+	record := func(name string) Middleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(ctx *Context) error {
+				order = append(order, name)
+				return next(ctx)
+			}
+		}
+	}
+	router, _ := newTestRouter(t)
+	router.Use(record("router1"), record("router2"))
+	router.Bot("a").Use(record("bot"))
+	router.Bot("a").HandleMessage(func(ctx *Context) error {
+		order = append(order, "handler")
+		return nil
+	})
+	router.ServeHTTP(httptest.NewRecorder(), newTestRouterCallback(t, "/callback/a"))
+	if want := []string{"router1", "router2", "bot", "handler"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("order = %q, want %q", order, want)
+	}
+}
+
+func TestRouterAddBotTwice(t *testing.T) {
+	router, _ := newTestRouter(t)
+	first := router.Bot("a")
+	if _, err := router.AddBot("a", testToken, testEncodingAESKey, "RobotA"); err == nil {
+		t.Fatal("expected an error when adding a bot twice")
+	}
+	if router.Bot("a") != first {
+		t.Fatal("the existing bot was replaced")
+	}
+}
@@ -11,6 +11,8 @@ const (
 	SendMessageTypeFile         SendMessageType = "file"
 	SendMessageTypeNews         SendMessageType = "news"
 	SendMessageTypeTemplateCard SendMessageType = "template_card"
+	SendMessageTypeModal        SendMessageType = "modal"
+	SendMessageTypeStream       SendMessageType = "stream"
 )
 
 type TextMessage struct {
@@ -26,3 +28,59 @@ type MarkdownMessage struct {
 type FileMessage struct {
 	MediaId string `json:"media_id"`
 }
+
+type TemplateCardType string
+
+const (
+	TemplateCardTypeTextNotice          TemplateCardType = "text_notice"
+	TemplateCardTypeNewsNotice          TemplateCardType = "news_notice"
+	TemplateCardTypeButtonInteraction   TemplateCardType = "button_interaction"
+	TemplateCardTypeVoteInteraction     TemplateCardType = "vote_interaction"
+	TemplateCardTypeMultipleInteraction TemplateCardType = "multiple_interaction"
+)
+
+type TemplateCardMessage struct {
+	CardType              TemplateCardType                `json:"card_type"`
+	Source                *TemplateCardSource             `json:"source,omitempty"`
+	MainTitle             *TemplateCardMainTitle          `json:"main_title,omitempty"`
+	SubTitleText          string                          `json:"sub_title_text,omitempty"`
+	HorizontalContentList []TemplateCardHorizontalContent `json:"horizontal_content_list,omitempty"`
+	JumpList              []TemplateCardJump              `json:"jump_list,omitempty"`
+	CardAction            *TemplateCardAction             `json:"card_action,omitempty"`
+	ButtonList            []TemplateCardButton            `json:"button_list,omitempty"`
+	TaskId                string                          `json:"task_id,omitempty"`
+}
+
+type TemplateCardSource struct {
+	IconUrl string `json:"icon_url,omitempty"`
+	Desc    string `json:"desc,omitempty"`
+}
+
+type TemplateCardMainTitle struct {
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+type TemplateCardHorizontalContent struct {
+	Type    int    `json:"type,omitempty"`
+	Keyname string `json:"keyname"`
+	Value   string `json:"value,omitempty"`
+	Url     string `json:"url,omitempty"`
+}
+
+type TemplateCardJump struct {
+	Type  int    `json:"type,omitempty"`
+	Title string `json:"title"`
+	Url   string `json:"url,omitempty"`
+}
+
+type TemplateCardAction struct {
+	Type int    `json:"type"`
+	Url  string `json:"url,omitempty"`
+}
+
+type TemplateCardButton struct {
+	Text  string `json:"text"`
+	Style int    `json:"style,omitempty"`
+	Key   string `json:"key"`
+}
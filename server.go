@@ -1,113 +1,377 @@
 package webot
 
 import (
-	"encoding/xml"
+	"context"
+	"errors"
 	"io"
 	"net/http"
-	"strings"
-
-	"github.com/imroc/webot/internal/wxbizmsgcrypt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Server struct {
 	log                       Logger
-	wxcpt                     *wxbizmsgcrypt.WXBizMsgCrypt
+	redactor                  *Redactor
+	audit                     AuditSink
+	credMu                    sync.RWMutex
+	credentials               []*serverCredential
+	receiverId                string
+	protocol                  Protocol
 	client                    *Client
-	token                     string
-	encodingAeskey            string
 	robotName                 string
-	messageHandlers           []MessageHandler
-	textMessageHandlers       []TextMessageHandler
-	imageMessageHandlers      []ImageMessageHandler
-	eventMessageHandlers      []EventMessageHandler
-	attachmentMessageHandlers []AttachmentMessageHandler
+	mu                        sync.RWMutex
+	messageHandlers           handlerList[MessageHandler]
+	textMessageHandlers       handlerList[TextMessageHandler]
+	imageMessageHandlers      handlerList[ImageMessageHandler]
+	eventMessageHandlers      handlerList[EventMessageHandler]
+	attachmentMessageHandlers handlerList[AttachmentMessageHandler]
+	attachmentActionHandlers  handlerList[attachmentActionRoute]
+	modalSubmitHandlers       handlerList[ModalSubmitHandler]
+	interactionHandlers       handlerList[InteractionHandler]
+	interactionDiscriminator  string
+	async                     *asyncDispatcher
+	dedupStore                DedupStore
+	dedupTTL                  time.Duration
+	dialogs                   map[string]*Dialog
+	sessionStore              SessionStore
+	sessionTTL                time.Duration
+	cancelKeywords            []string
+	askTimeout                time.Duration
+	askMu                     sync.Mutex
+	askWaiters                map[string]chan string
+	replayWindow              time.Duration
+	nonceStore                NonceStore
+	middlewares               []Middleware
+	router                    *Router
+	callbackPath              string
+	healthPath                string
+	readTimeout               time.Duration
+	writeTimeout              time.Duration
+	httpMu                    sync.Mutex
+	httpServer                *http.Server
+	closing                   atomic.Bool
+	inflight                  pendingCounter
+	passiveReplyTimeout       time.Duration
+	streamMu                  sync.Mutex
+	streams                   map[string]*StreamWriter
 }
 
-func NewServer(token, encodingAeskey, robotName string) *Server {
-	return &Server{
-		token:          token,
-		encodingAeskey: encodingAeskey,
-		wxcpt:          wxbizmsgcrypt.NewWXBizMsgCrypt(token, encodingAeskey, "", wxbizmsgcrypt.XmlType),
-		log:            createDefaultLogger(),
-		client:         NewClient(),
+// NewServer creates a Server, it returns an error if the EncodingAESKey is invalid.
+func NewServer(token, encodingAeskey, robotName string) (*Server, error) {
+	redactor := NewRedactor()
+	s := &Server{
+		robotName:                robotName,
+		log:                      newRedactLogger(createDefaultLogger(), redactor),
+		redactor:                 redactor,
+		client:                   NewClient(),
+		sessionStore:             NewMemorySessionStore(),
+		sessionTTL:               10 * time.Minute,
+		cancelKeywords:           []string{"cancel", "取消"},
+		askTimeout:               5 * time.Minute,
+		askWaiters:               make(map[string]chan string),
+		callbackPath:             "/",
+		healthPath:               "/healthz",
+		readTimeout:              10 * time.Second,
+		writeTimeout:             10 * time.Second,
+		interactionDiscriminator: "action",
+		passiveReplyTimeout:      3 * time.Second,
+		streams:                  make(map[string]*StreamWriter),
+	}
+	if err := s.SetCredentials(Credential{Token: token, EncodingAESKey: encodingAeskey}); err != nil {
+		return nil, err
 	}
+	return s, nil
 }
 
 func (s *Server) GetClient() *Client {
 	return s.client
 }
 
+func (s *Server) SetClient(client *Client) *Server {
+	s.client = client
+	return s
+}
+
+// SetLogger sets the logger of the server and its client, the secrets in
+// the lines are masked by the server's Redactor.
 func (s *Server) SetLogger(logger Logger) *Server {
-	s.log = logger
+	s.log = newRedactLogger(logger, s.redactor)
+	s.client.SetLogger(logger)
+	return s
+}
+
+// SetRedactor sets the Redactor masking secrets and message fields in the
+// logs of the server, e.g. NewRedactor("content") hides what users write to
+// the bot. Audit records keep the message fields, only the secrets of the
+// webhook and response urls are masked. The client, which may be shared
+// with other bots of a Router, keeps its own Redactor, see
+// Client.SetRedactor.
+func (s *Server) SetRedactor(redactor *Redactor) *Server {
+	s.redactor = redactor
+	s.log = newRedactLogger(s.log.(*redactLogger).l, redactor)
+	return s
+}
+
+// Use adds middlewares wrapping the handling of every received message.
+func (s *Server) Use(middlewares ...Middleware) *Server {
+	s.mu.Lock()
+	s.middlewares = append(s.middlewares[:len(s.middlewares):len(s.middlewares)], middlewares...)
+	s.mu.Unlock()
 	return s
 }
 
-func (s *Server) verifyURL(msgSignature, timestamp, nonce, echoStr string) ([]byte, error) {
-	result, err := s.wxcpt.VerifyURL(msgSignature, timestamp, nonce, echoStr)
+// DecryptMsg verifies and decrypts a callback body, detecting its protocol
+// unless set with SetProtocol, and parses the message.
+func (s *Server) DecryptMsg(msgSignature, timestamp, nonce string, data []byte) (*CallbackMessage, error) {
+	protocol := s.detectProtocol(nil, data)
+	rawMsg, _, err := s.decrypt(protocol, msgSignature, timestamp, nonce, data)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
+	msg := &CallbackMessage{}
+	if err := unmarshalMessage(protocol, rawMsg, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
-func (s *Server) DecryptJsonMsg(msgSignature, timestamp, nonce string, data []byte) (msg *CallbackMessage, err error) {
-	rawMsg, cryptErr := s.wxcpt.DecryptMsg(msgSignature, timestamp, nonce, data)
-	if cryptErr != nil {
-		return nil, cryptErr
-	}
-	msg = &CallbackMessage{}
-	err = xml.Unmarshal(rawMsg, &msg)
-	if err != nil {
-		msg = nil
-	}
-	return
+// DecryptJsonMsg is the former name of DecryptMsg.
+//
+// Deprecated: use DecryptMsg.
+func (s *Server) DecryptJsonMsg(msgSignature, timestamp, nonce string, data []byte) (*CallbackMessage, error) {
+	return s.DecryptMsg(msgSignature, timestamp, nonce, data)
 }
 
 type (
-	MessageHandler           func(client *Client, msg CallbackMessage) error
-	TextMessageHandler       func(client *Client, msg CallbackMessageCommonItem, text Text) error
-	ImageMessageHandler      func(client *Client, msg CallbackMessageCommonItem, image Image) error
-	EventMessageHandler      func(client *Client, msg CallbackMessageCommonItem, image Event) error
-	AttachmentMessageHandler func(client *Client, msg CallbackMessageCommonItem, image Attachment) error
+	MessageHandler           func(ctx *Context) error
+	TextMessageHandler       func(ctx *Context, text Text) error
+	ImageMessageHandler      func(ctx *Context, image Image) error
+	EventMessageHandler      func(ctx *Context, event Event) error
+	AttachmentMessageHandler func(ctx *Context, attachment Attachment) error
+	ModalSubmitHandler       func(ctx *Context, submit ModalSubmit) error
 )
 
-func (s *Server) HandleTextMessage(fn TextMessageHandler) *Server {
-	s.textMessageHandlers = append(s.textMessageHandlers, fn)
-	return s
+// HandleTextMessage registers a handler for text messages, the returned
+// Handle unregisters it. Registering is safe while serving callbacks.
+func (s *Server) HandleTextMessage(fn TextMessageHandler, opts ...HandlerOption) *Handle {
+	return s.textMessageHandlers.add("", fn, opts)
 }
 
-func (s *Server) HandleImageMessage(fn ImageMessageHandler) *Server {
-	s.imageMessageHandlers = append(s.imageMessageHandlers, fn)
-	return s
+func (s *Server) HandleImageMessage(fn ImageMessageHandler, opts ...HandlerOption) *Handle {
+	return s.imageMessageHandlers.add("", fn, opts)
 }
 
-func (s *Server) HandleEventMessage(fn EventMessageHandler) *Server {
-	s.eventMessageHandlers = append(s.eventMessageHandlers, fn)
-	return s
+// HandleEventMessage registers a handler for all events, see HandleEvent
+// to handle a single event type.
+func (s *Server) HandleEventMessage(fn EventMessageHandler, opts ...HandlerOption) *Handle {
+	return s.eventMessageHandlers.add("", fn, opts)
+}
+
+func (s *Server) HandleAttachmentMessage(fn AttachmentMessageHandler, opts ...HandlerOption) *Handle {
+	return s.attachmentMessageHandlers.add("", fn, opts)
+}
+
+func (s *Server) HandleModalSubmit(fn ModalSubmitHandler, opts ...HandlerOption) *Handle {
+	return s.modalSubmitHandlers.add("", fn, opts)
 }
 
-func (s *Server) HandleAttachmentMessage(fn AttachmentMessageHandler) *Server {
-	s.attachmentMessageHandlers = append(s.attachmentMessageHandlers, fn)
+// HandleMessage registers a handler for all messages, it runs before the
+// handlers of the specific message types.
+func (s *Server) HandleMessage(fn MessageHandler, opts ...HandlerOption) *Handle {
+	return s.messageHandlers.add("", fn, opts)
+}
+
+// EnableAsync makes the server acknowledge callbacks immediately and run the
+// handlers on a bounded worker pool.
+func (s *Server) EnableAsync(opts AsyncOptions) *Server {
+	s.async = newAsyncDispatcher(s, opts)
 	return s
 }
 
-func (s *Server) HandleMessage(fn MessageHandler) *Server {
-	s.messageHandlers = append(s.messageHandlers, fn)
+// EnableDedup drops callbacks whose MsgId was already received within ttl
+// (5 minutes by default), a nil store means an in-memory store.
+func (s *Server) EnableDedup(store DedupStore, ttl time.Duration) *Server {
+	if store == nil {
+		store = NewMemoryDedupStore()
+	}
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	s.dedupStore = store
+	s.dedupTTL = ttl
 	return s
 }
 
-func (s *Server) cleanContent(content string) string {
-	str := strings.ReplaceAll(content, "@"+s.robotName, "")
-	return strings.TrimSpace(str)
+func (s *Server) isDuplicate(msg *CallbackMessage) bool {
+	if s.dedupStore == nil || msg.MsgId == "" {
+		return false
+	}
+	added, err := s.dedupStore.Add(msg.MsgId, s.dedupTTL)
+	if err != nil {
+		s.msgLogger(msg).Errorf("failed to check duplicate message: %v", err)
+		return false
+	}
+	return !added
+}
+
+// forgetMessage releases the dedup key of a message which was not handled,
+// so that its redelivery is not dropped as a duplicate.
+func (s *Server) forgetMessage(msg *CallbackMessage) {
+	if s.dedupStore == nil || msg.MsgId == "" {
+		return
+	}
+	if err := s.dedupStore.Remove(msg.MsgId); err != nil {
+		s.msgLogger(msg).Errorf("failed to release duplicate check: %v", err)
+	}
+}
+
+// msgLogger returns the logger with the ids of msg attached.
+func (s *Server) msgLogger(msg *CallbackMessage) Logger {
+	return loggerWith(s.log, "msg_id", msg.MsgId, "chat_id", msg.ChatId, "user_id", msg.From.UserId)
+}
+
+func (s *Server) validateMessage(msg *CallbackMessage) error {
+	switch msg.MsgType {
+	case CallbackMessageTypeText:
+		if msg.Text == nil {
+			return errors.New("no text found in text message")
+		}
+	case CallbackMessageTypeImage:
+		if msg.Image == nil {
+			return errors.New("no image found in image message")
+		}
+	case CallbackMessageTypeEvent:
+		if msg.Event == nil {
+			return errors.New("no event found in event message")
+		}
+	case CallbackMessageTypeAttachment:
+		if msg.Attachment == nil {
+			return errors.New("no attachment found in attachment message")
+		}
+	case CallbackMessageTypeInteraction:
+		if msg.Interaction == nil {
+			return errors.New("no interaction found in interaction message")
+		}
+	case CallbackMessageTypeModalSubmit:
+		if msg.ModalSubmit == nil {
+			return errors.New("no modal submit found in modal_submit message")
+		}
+	case CallbackMessageTypeStream:
+		if msg.Stream == nil {
+			return errors.New("no stream found in stream message")
+		}
+	}
+	return nil
+}
+
+// errPropagationStopped is returned by runHandler when the handler was
+// skipped because a previous one stopped the propagation.
+var errPropagationStopped = errors.New("webot: propagation stopped")
+
+// runHandler runs fn and logs its error. A nil error means fn returned nil,
+// not timed out nor skipped.
+func (s *Server) runHandler(ctx *Context, fn func(ctx *Context) error) error {
+	if ctx.IsPropagationStopped() {
+		return errPropagationStopped
+	}
+	var err error
+	if s.async != nil {
+		err = s.async.run(ctx, fn)
+	} else {
+		err = fn(ctx)
+	}
+	if err != nil {
+		ctx.Logger.Errorf("failed to handle message: %v", err)
+	}
+	return err
+}
+
+func (s *Server) dispatch(msg CallbackMessage, reply *passiveReply) {
+	var middlewares []Middleware
+	if s.router != nil {
+		middlewares = append(middlewares, s.router.getMiddlewares()...)
+	}
+	s.mu.RLock()
+	middlewares = append(middlewares, s.middlewares...)
+	s.mu.RUnlock()
+	handler := MessageHandler(s.handle)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	ctx := s.newContext(context.Background(), msg)
+	ctx.reply = reply
+	if err := handler(ctx); err != nil {
+		ctx.Logger.Errorf("failed to handle message: %v", err)
+	}
+}
+
+func (s *Server) handle(ctx *Context) error {
+	msg := ctx.Message
+	for _, handler := range s.messageHandlers.get("") {
+		s.runHandler(ctx, handler)
+	}
+	switch msg.MsgType {
+	case CallbackMessageTypeText:
+		if ctx.IsPropagationStopped() || s.handleDialog(ctx, *msg.Text) {
+			return nil
+		}
+		for _, handler := range s.textMessageHandlers.get("") {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.Text) })
+		}
+	case CallbackMessageTypeImage:
+		for _, handler := range s.imageMessageHandlers.get("") {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.Image) })
+		}
+	case CallbackMessageTypeEvent:
+		for _, handler := range s.eventMessageHandlers.get("", string(msg.Event.EventType)) {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.Event) })
+		}
+	case CallbackMessageTypeAttachment:
+		for _, handler := range s.attachmentMessageHandlers.get("") {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.Attachment) })
+		}
+		s.handleAttachmentActions(ctx, *msg.Attachment)
+	case CallbackMessageTypeInteraction:
+		for _, handler := range s.interactionHandlersOf(*msg.Interaction) {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.Interaction) })
+		}
+	case CallbackMessageTypeModalSubmit:
+		for _, handler := range s.modalSubmitHandlers.get("") {
+			handler := handler
+			s.runHandler(ctx, func(ctx *Context) error { return handler(ctx, *msg.ModalSubmit) })
+		}
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler so the server can be mounted on any mux.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.CallbackHandler(w, r)
 }
 
 func (s *Server) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	received := time.Now()
 	urlQuery := r.URL.Query()
 	msg_signature := urlQuery.Get("msg_signature")
 	timestamp := urlQuery.Get("timestamp")
 	nonce := urlQuery.Get("nonce")
 	echostr := urlQuery.Get("echostr")
 
+	// Count the request before checking closing, so that Shutdown either
+	// waits for it or the request sees closing and is rejected.
+	s.inflight.add()
+	defer s.inflight.done()
+	if s.closing.Load() {
+		http.Error(w, ErrServerClosed.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
 	switch r.Method {
 	case "POST":
 		body, err := io.ReadAll(r.Body)
@@ -116,73 +380,60 @@ func (s *Server) CallbackHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		body, cryptErr := s.wxcpt.DecryptMsg(msg_signature, timestamp, nonce, body)
-		if cryptErr != nil {
-			s.log.Errorf("failed to decrypt message: %s", cryptErr.Error())
-			http.Error(w, cryptErr.Error(), http.StatusBadRequest)
+		protocol := s.detectProtocol(r, body)
+		body, crypt, err := s.decrypt(protocol, msg_signature, timestamp, nonce, body)
+		if err != nil {
+			s.log.Errorf("failed to decrypt message: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.checkReplay(timestamp, nonce); err != nil {
+			s.log.Errorf("rejected replayed callback: %v", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
-		s.log.Infof("received body: \n%s", string(body))
+		s.log.Debugf("received body: \n%s", string(body))
 		var msg CallbackMessage
-		err = xml.Unmarshal(body, &msg)
+		err = unmarshalMessage(protocol, body, &msg)
 		if err != nil {
-			s.log.Errorf("failed to unmarshal xml message: %v", err)
+			s.log.Errorf("failed to unmarshal %s message: %v", protocol, err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		for _, handler := range s.messageHandlers {
-			handler(s.client, msg)
+		if err := s.validateMessage(&msg); err != nil {
+			s.log.Errorf("invalid message: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		switch msg.MsgType {
-		case CallbackMessageTypeText:
-			if msg.Text == nil {
-				errMsg := "no text found in text message"
-				s.log.Errorf(errMsg)
-				http.Error(w, errMsg, http.StatusBadRequest)
-				return
-			}
-			for _, handler := range s.textMessageHandlers {
-				handler(s.client, msg.CallbackMessageCommonItem, *msg.Text)
-			}
-		case CallbackMessageTypeImage:
-			if msg.Image == nil {
-				errMsg := "no image found in image message"
-				s.log.Errorf(errMsg)
-				http.Error(w, errMsg, http.StatusBadRequest)
-				return
-			}
-			for _, handler := range s.imageMessageHandlers {
-				handler(s.client, msg.CallbackMessageCommonItem, *msg.Image)
-			}
-		case CallbackMessageTypeEvent:
-			if msg.Event == nil {
-				errMsg := "no event found in event message"
-				s.log.Errorf(errMsg)
-				http.Error(w, errMsg, http.StatusBadRequest)
-				return
-			}
-			for _, handler := range s.eventMessageHandlers {
-				handler(s.client, msg.CallbackMessageCommonItem, *msg.Event)
-			}
-		case CallbackMessageTypeAttachment:
-			if msg.Attachment == nil {
-				errMsg := "no attachment found in attachment message"
-				s.log.Errorf(errMsg)
-				http.Error(w, errMsg, http.StatusBadRequest)
-				return
-			}
-			for _, handler := range s.attachmentMessageHandlers {
-				handler(s.client, msg.CallbackMessageCommonItem, *msg.Attachment)
+		s.auditMessage(&msg)
+		if msg.MsgType == CallbackMessageTypeStream {
+			s.respondStream(w, msg, received, nonce, crypt)
+			return
+		}
+		if s.isDuplicate(&msg) {
+			s.msgLogger(&msg).Infof("ignore duplicate message")
+			return
+		}
+		if s.deliverAnswer(&msg) {
+			return
+		}
+		if s.async != nil {
+			if err := s.async.submit(msg); err != nil {
+				s.msgLogger(&msg).Errorf("failed to enqueue message: %v", err)
+				s.forgetMessage(&msg)
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
 			}
+			return
 		}
+		s.dispatchWithReply(w, msg, received, nonce, crypt)
 	case "GET":
 		if echostr != "" {
-			echostr, cryptErr := s.verifyURL(msg_signature, timestamp, nonce, echostr)
-			if cryptErr != nil {
-				s.log.Errorf("failed to verify url: %s", cryptErr.Error())
-				http.Error(w, cryptErr.Error(), http.StatusBadRequest)
+			echostr, err := s.verifyURL(msg_signature, timestamp, nonce, echostr)
+			if err != nil {
+				s.log.Errorf("failed to verify url: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
 			} else {
-				s.log.Infof("verifyUrl success echostr: %s", echostr)
+				s.log.Infof("verify url success")
 				w.Write(echostr)
 			}
 			return
@@ -0,0 +1,137 @@
+package webot
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/imroc/webot/internal/tests"
+	"github.com/imroc/webot/msgcrypt"
+)
+
+const (
+	testToken          = "QDG6eK"
+	testEncodingAESKey = "jWmYm7qr5nMoAUwZRjGtBxmz3KA1tkAj3ykkR6q2B2C"
+	testTimestamp      = "1409659813"
+	testNonce          = "1372623149"
+)
+
+func newTestServer(t *testing.T) *Server {
+	s, err := NewServer(testToken, testEncodingAESKey, "RobotA")
+	tests.AssertNoError(t, err)
+	return s.SetLogger(NewLogger(io.Discard, "", log.LstdFlags))
+}
+
+func newTestCrypt(t *testing.T, protocol msgcrypt.Protocol) *msgcrypt.Crypt {
+	crypt, err := msgcrypt.New(testToken, testEncodingAESKey, "", protocol)
+	tests.AssertNoError(t, err)
+	return crypt
+}
+
+// newTestCallback returns a callback request carrying msg encrypted in the
+// envelope of the protocol.
+func newTestCallback(t *testing.T, protocol msgcrypt.Protocol, msg []byte) *http.Request {
+	return newTestCallbackAt(t, protocol, msg, testTimestamp, testNonce)
+}
+
+func newTestCallbackAt(t *testing.T, protocol msgcrypt.Protocol, msg []byte, timestamp, nonce string) *http.Request {
+	crypt := newTestCrypt(t, protocol)
+	body, err := crypt.EncryptMsg(msg, timestamp, nonce)
+	tests.AssertNoError(t, err)
+	env, err := crypt.ParseEnvelope(body)
+	tests.AssertNoError(t, err)
+	query := url.Values{}
+	query.Set("msg_signature", crypt.Sign(timestamp, nonce, env.Encrypt))
+	query.Set("timestamp", timestamp)
+	query.Set("nonce", nonce)
+	return httptest.NewRequest(http.MethodPost, "/?"+query.Encode(), bytes.NewReader(body))
+}
+
+// decryptTestResponse verifies and decrypts the reply written in rec.
+func decryptTestResponse(t *testing.T, protocol msgcrypt.Protocol, rec *httptest.ResponseRecorder) []byte {
+	crypt := newTestCrypt(t, protocol)
+	env, err := crypt.ParseEnvelope(rec.Body.Bytes())
+	tests.AssertNoError(t, err)
+	data, err := crypt.Decrypt(env.Encrypt)
+	tests.AssertNoError(t, err)
+	return data
+}
+
+// testBuffer is a goroutine-safe buffer collecting log lines.
+type testBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *testBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *testBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newTestTextMessage returns the text message fixture with the msg id and content replaced.
+func newTestTextMessage(t *testing.T, msgId, content string) []byte {
+	msg := tests.GetTestFileContent(t, "msg-text.xml")
+	msg = bytes.Replace(msg, []byte("abcdabcdabcd"), []byte(msgId), 1)
+	return bytes.Replace(msg, []byte("@RobotA hello robot"), []byte(content), 1)
+}
+
+// serveTestText delivers a text message to s and returns the response.
+func serveTestText(t *testing.T, s *Server, msgId, content string) *httptest.ResponseRecorder {
+	return serveTestTextMessage(t, s, newTestTextMessage(t, msgId, content))
+}
+
+// serveTestTextMessage delivers the XML message to s and returns the response.
+func serveTestTextMessage(t *testing.T, s *Server, msg []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, msg))
+	return rec
+}
+
+// testWebhook is a webhook endpoint recording the messages sent to it.
+type testWebhook struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []string
+}
+
+func newTestWebhook(t *testing.T) *testWebhook {
+	w := &testWebhook{}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.mu.Lock()
+		w.messages = append(w.messages, string(body))
+		w.mu.Unlock()
+		rw.Header().Set("Error-Code", "0")
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+	}))
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *testWebhook) Messages() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.messages...)
+}
+
+// serveTestTextVia is like serveTestText with the message's webhook url set to webhook.
+func serveTestTextVia(t *testing.T, s *Server, webhook *testWebhook, msgId, content string) *httptest.ResponseRecorder {
+	msg := newTestTextMessage(t, msgId, content)
+	msg = bytes.Replace(msg, []byte(" <![CDATA[https://qyapi.weixin.qq.com/xxxxxxx]]>"), []byte("<![CDATA["+webhook.URL+"/send?key=test]]>"), 1)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newTestCallback(t, msgcrypt.XML, msg))
+	return rec
+}
@@ -0,0 +1,199 @@
+package webot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Session holds the state of a dialog between the bot and one user in one chat.
+type Session struct {
+	Dialog    string            `json:"dialog"`
+	Step      string            `json:"step"`
+	Data      map[string]string `json:"data"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+func (s *Session) Get(key string) string {
+	return s.Data[key]
+}
+
+func (s *Session) Set(key, value string) {
+	if s.Data == nil {
+		s.Data = make(map[string]string)
+	}
+	s.Data[key] = value
+}
+
+func (s *Session) expired() bool {
+	return !s.ExpiresAt.IsZero() && time.Now().After(s.ExpiresAt)
+}
+
+func (s *Session) clone() *Session {
+	cs := *s
+	cs.Data = make(map[string]string, len(s.Data))
+	for k, v := range s.Data {
+		cs.Data[k] = v
+	}
+	return &cs
+}
+
+// SessionStore persists sessions, keyed by chat id and user id.
+type SessionStore interface {
+	// Load returns nil if there is no session for key or it has expired.
+	Load(key string) (*Session, error)
+	Save(key string, session *Session) error
+	Delete(key string) error
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in process memory.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]*Session
+	lastSweep time.Time
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session)}
+}
+
+func (m *MemorySessionStore) Load(key string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	session, ok := m.sessions[key]
+	if !ok {
+		return nil, nil
+	}
+	if session.expired() {
+		delete(m.sessions, key)
+		return nil, nil
+	}
+	return session.clone(), nil
+}
+
+func (m *MemorySessionStore) Save(key string, session *Session) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Sweep expired sessions at most once per session ttl.
+	if !session.ExpiresAt.IsZero() && now.Sub(m.lastSweep) > session.ExpiresAt.Sub(now) {
+		for k, s := range m.sessions {
+			if s.expired() {
+				delete(m.sessions, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.sessions[key] = session.clone()
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(key string) error {
+	m.mu.Lock()
+	delete(m.sessions, key)
+	m.mu.Unlock()
+	return nil
+}
+
+// FileSessionStore is a SessionStore that keeps one JSON file per session in a directory.
+type FileSessionStore struct {
+	dir       string
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+func (f *FileSessionStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (f *FileSessionStore) Load(key string) (*Session, error) {
+	data, err := os.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	if session.expired() {
+		return nil, f.Delete(key)
+	}
+	return session, nil
+}
+
+func (f *FileSessionStore) Save(key string, session *Session) error {
+	if !session.ExpiresAt.IsZero() {
+		f.sweep(time.Until(session.ExpiresAt))
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".session-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+func (f *FileSessionStore) Delete(key string) error {
+	err := os.Remove(f.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// sweep removes expired session files, at most once per ttl.
+func (f *FileSessionStore) sweep(ttl time.Duration) {
+	f.mu.Lock()
+	if time.Since(f.lastSweep) < ttl {
+		f.mu.Unlock()
+		return
+	}
+	f.lastSweep = time.Now()
+	f.mu.Unlock()
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := filepath.Join(f.dir, entry.Name())
+		data, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		session := &Session{}
+		if json.Unmarshal(data, session) == nil && session.expired() {
+			os.Remove(name)
+		}
+	}
+}
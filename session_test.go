@@ -0,0 +1,71 @@
+package webot
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSessionStores(t *testing.T) {
+	fileStore, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores := []struct {
+		name  string
+		store SessionStore
+	}{
+		{"memory", NewMemorySessionStore()},
+		{"file", fileStore},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			live := &Session{Dialog: "d", Step: "s", ExpiresAt: time.Now().Add(time.Hour)}
+			live.Set("k", "v")
+			if err := tt.store.Save("live", live); err != nil {
+				t.Fatal(err)
+			}
+			expired := &Session{Dialog: "d", Step: "s", ExpiresAt: time.Now().Add(-time.Second)}
+			if err := tt.store.Save("expired", expired); err != nil {
+				t.Fatal(err)
+			}
+			session, err := tt.store.Load("live")
+			if err != nil || session == nil || session.Step != "s" || session.Get("k") != "v" {
+				t.Fatalf("Load(live) = %+v, %v", session, err)
+			}
+			if session, err := tt.store.Load("expired"); err != nil || session != nil {
+				t.Fatalf("Load(expired) = %+v, %v, want nil", session, err)
+			}
+			if err := tt.store.Delete("live"); err != nil {
+				t.Fatal(err)
+			}
+			if session, err := tt.store.Load("live"); err != nil || session != nil {
+				t.Fatalf("Load after Delete = %+v, %v, want nil", session, err)
+			}
+			if err := tt.store.Delete("live"); err != nil {
+				t.Fatalf("Delete of a missing session: %v", err)
+			}
+		})
+	}
+}
+
+func TestFileSessionStoreSweep(t *testing.T) {
+	f, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := 20 * time.Millisecond
+	if err := f.Save("abandoned", &Session{ExpiresAt: time.Now().Add(ttl)}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * ttl)
+	if err := f.Save("active", &Session{ExpiresAt: time.Now().Add(ttl)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.path("abandoned")); !os.IsNotExist(err) {
+		t.Fatalf("abandoned session file still exists: %v", err)
+	}
+	if _, err := os.Stat(f.path("active")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,132 @@
+package webot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imroc/webot/msgcrypt"
+)
+
+// ErrStreamUnavailable is returned by Context.Stream when the callback
+// response was already written, e.g. in async mode or after the passive
+// reply timeout.
+var ErrStreamUnavailable = errors.New("webot: stream reply is only available in the callback response")
+
+// streamTTL is how long an unfinished stream is kept without refresh.
+const streamTTL = 10 * time.Minute
+
+// Stream is the content of a stream refresh callback.
+type Stream struct {
+	Id string `xml:"Id" json:"id"`
+}
+
+// StreamReply is the stream reply of the AI bot protocol.
+type StreamReply struct {
+	Id      string `json:"id"`
+	Finish  bool   `json:"finish"`
+	Content string `json:"content"`
+}
+
+// StreamWriter accumulates the content of a stream reply, the server
+// answers the refresh callbacks of the stream with the content written so
+// far until the writer is closed.
+type StreamWriter struct {
+	id        string
+	mu        sync.Mutex
+	buf       strings.Builder
+	finished  bool
+	updatedAt time.Time
+}
+
+func (w *StreamWriter) Id() string {
+	return w.id
+}
+
+func (w *StreamWriter) Write(p []byte) (int, error) {
+	return w.WriteString(string(p))
+}
+
+func (w *StreamWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.finished {
+		return 0, errors.New("webot: write to finished stream")
+	}
+	w.updatedAt = time.Now()
+	return w.buf.WriteString(s)
+}
+
+// Close finishes the stream.
+func (w *StreamWriter) Close() error {
+	w.mu.Lock()
+	w.finished = true
+	w.updatedAt = time.Now()
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *StreamWriter) reply() *StreamReply {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &StreamReply{Id: w.id, Finish: w.finished, Content: w.buf.String()}
+}
+
+func (w *StreamWriter) expired() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.updatedAt) > streamTTL
+}
+
+// Stream starts a stream reply in the callback response and returns the
+// writer to send its content, it must be closed to finish the stream.
+func (c *Context) Stream() (*StreamWriter, error) {
+	if c.reply == nil {
+		return nil, ErrStreamUnavailable
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	w := &StreamWriter{id: hex.EncodeToString(b), updatedAt: time.Now()}
+	s := c.server
+	s.streamMu.Lock()
+	for id, stream := range s.streams {
+		if stream.expired() {
+			delete(s.streams, id)
+		}
+	}
+	s.streams[w.id] = w
+	s.streamMu.Unlock()
+	if !c.reply.set(&ReplyMessage{MsgType: SendMessageTypeStream, Stream: w.reply()}) {
+		s.streamMu.Lock()
+		delete(s.streams, w.id)
+		s.streamMu.Unlock()
+		return nil, ErrStreamUnavailable
+	}
+	return w, nil
+}
+
+// respondStream answers a stream refresh callback with the content written so far.
+func (s *Server) respondStream(w http.ResponseWriter, msg CallbackMessage, received time.Time, nonce string, crypt *msgcrypt.Crypt) {
+	s.streamMu.Lock()
+	stream, ok := s.streams[msg.Stream.Id]
+	s.streamMu.Unlock()
+	var reply *StreamReply
+	if ok {
+		reply = stream.reply()
+	} else {
+		s.msgLogger(&msg).Warnf("stream %s not found", msg.Stream.Id)
+		reply = &StreamReply{Id: msg.Stream.Id, Finish: true}
+	}
+	if reply.Finish {
+		s.streamMu.Lock()
+		delete(s.streams, msg.Stream.Id)
+		s.streamMu.Unlock()
+	}
+	s.writeReply(w, msg, received, &ReplyMessage{MsgType: SendMessageTypeStream, Stream: reply}, nonce, crypt)
+}